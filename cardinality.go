@@ -0,0 +1,89 @@
+package redimo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// Cardinality counters keep the number of fields of a hash, or members of a set or sorted set, in a
+// single item in a separate partition, so that HLEN, SCARD and ZCARD read one item instead of counting
+// the whole key. Writes that already run as transactions update the counter in the same transaction, so
+// HMSET, each chunk of HMSETCHUNKED and each transaction of the STORE commands keep it exact. Single item
+// writes find out whether they added or removed an item from the returned old values and then adjust the
+// counter with a second write. A failure between the two leaves the counter off by one – and since SADDBULK
+// and SREMBULK write counted sets a member at a time in concurrent batches, a failure there can leave it off
+// by up to one for each batch in flight, that is by at most SBulkOptions.Concurrency (4 by default).
+
+func (c Client) cardinalityKey(key string) keyDef {
+	return keyDef{pk: strings.Join([]string{"_redimo", "card", key}, "/"), sk: emptySK}
+}
+
+// countsCardinality is false for the internal keys redimo keeps for itself, which are never counted.
+func (c Client) countsCardinality(key string) bool {
+	return c.cardinalityCounters && !strings.HasPrefix(key, internalPrefix)
+}
+
+func (c Client) cardinalityBuilder(delta int64) expressionBuilder {
+	builder := newExpresionBuilder()
+	builder.clauses["ADD"] = append(builder.clauses["ADD"], fmt.Sprintf("#%v :delta", vk))
+	builder.keys[vk] = struct{}{}
+	builder.values["delta"] = IntValue{delta}.ToAV()
+
+	return builder
+}
+
+// cardinalityActions returns the transaction action that adjusts the counter of the key, if any.
+func (c Client) cardinalityActions(key string, delta int64) []dynamodb.TransactWriteItem {
+	if !c.countsCardinality(key) || delta == 0 {
+		return nil
+	}
+
+	builder := c.cardinalityBuilder(delta)
+
+	return []dynamodb.TransactWriteItem{
+		{
+			Update: &dynamodb.Update{
+				ExpressionAttributeNames:  builder.expressionAttributeNames(),
+				ExpressionAttributeValues: builder.expressionAttributeValues(),
+				Key:                       c.cardinalityKey(key).toAV(c),
+				TableName:                 aws.String(c.table),
+				UpdateExpression:          builder.updateExpression(),
+			},
+		},
+	}
+}
+
+func (c Client) adjustCardinality(key string, delta int64) error {
+	if !c.countsCardinality(key) || delta == 0 {
+		return nil
+	}
+
+	builder := c.cardinalityBuilder(delta)
+
+	_, err := c.ddbClient.UpdateItemRequest(&dynamodb.UpdateItemInput{
+		ExpressionAttributeNames:  builder.expressionAttributeNames(),
+		ExpressionAttributeValues: builder.expressionAttributeValues(),
+		Key:                       c.cardinalityKey(key).toAV(c),
+		TableName:                 aws.String(c.table),
+		UpdateExpression:          builder.updateExpression(),
+	}).Send(context.TODO())
+
+	return err
+}
+
+func (c Client) cardinality(key string) (count int64, err error) {
+	resp, err := c.ddbClient.GetItemRequest(&dynamodb.GetItemInput{
+		ConsistentRead: aws.Bool(c.consistentReads),
+		Key:            c.cardinalityKey(key).toAV(c),
+		TableName:      aws.String(c.table),
+	}).Send(context.TODO())
+	if err == nil {
+		count = parseItem(resp.Item, c).val.Int()
+	}
+
+	return
+}
@@ -2,24 +2,19 @@ package redimo
 
 import (
 	"context"
-	"strings"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 )
 
 func (c Client) HGET(key string, field string) (val ReturnValue, err error) {
-	resp, err := c.ddbClient.GetItemRequest(&dynamodb.GetItemInput{
-		ConsistentRead: aws.Bool(c.consistentReads),
-		Key: keyDef{
-			pk: key,
-			sk: field,
-		}.toAV(c),
-		ProjectionExpression: aws.String(strings.Join([]string{vk}, ", ")),
-		TableName:            aws.String(c.table),
-	}).Send(context.TODO())
+	item, err := c.hField(key, field)
 	if err == nil {
-		val = parseItem(resp.Item, c).val
+		val = parseItem(item, c).val
 	}
 
 	return
@@ -31,6 +26,7 @@ func (c Client) HSET(key string, fieldValues map[string]Value) (newlySavedFields
 	for field, value := range fieldValues {
 		builder := newExpresionBuilder()
 		builder.updateSetAV(vk, value.ToAV())
+		c.hRemoveExpiry(&builder)
 
 		resp, err := c.ddbClient.UpdateItemRequest(&dynamodb.UpdateItemInput{
 			ConditionExpression:       builder.conditionExpression(),
@@ -46,20 +42,128 @@ func (c Client) HSET(key string, fieldValues map[string]Value) (newlySavedFields
 			return newlySavedFields, err
 		}
 
-		if len(resp.Attributes) < 1 {
+		if !c.hLive(resp.Attributes) {
 			newlySavedFields[field] = value
 		}
+
+		if len(resp.Attributes) < 1 {
+			if err = c.adjustCardinality(key, 1); err != nil {
+				return newlySavedFields, err
+			}
+		}
 	}
 
 	return
 }
 
+// ErrHTooManyFields is returned by HMSET when the fields can't be set in a single transaction. Use
+// HMSETCHUNKED to set them in several.
+var ErrHTooManyFields = errors.New("too many fields to set atomically")
+
+// HChunkError is returned by HMSETCHUNKED when one of the chunks fails. The chunks before it were set, and the
+// chunks after it were not attempted.
+type HChunkError struct {
+	Chunk  int
+	Chunks int
+	Fields []string
+	Err    error
+}
+
+func (e HChunkError) Error() string {
+	return fmt.Sprintf("chunk %v of %v (%v fields) failed: %v", e.Chunk+1, e.Chunks, len(e.Fields), e.Err)
+}
+
+func (e HChunkError) Unwrap() error {
+	return e.Err
+}
+
+// hMaxFields is the number of fields that fit in a single transaction, leaving room for the cardinality counter.
+func (c Client) hMaxFields(key string) int {
+	if c.countsCardinality(key) {
+		return maxTransactionItems - 1
+	}
+
+	return maxTransactionItems
+}
+
+// HMSET sets all the given fields atomically, in a single transaction. A transaction holds at most 100 fields
+// (99 with cardinality counters) and 4MB, and more fields return ErrHTooManyFields without setting any.
+//
+// Cost is O(N) / 2N WCUs, where N is the number of fields.
+//
+// Works similar to https://redis.io/commands/hmset
 func (c Client) HMSET(key string, fieldValues map[string]Value) (err error) {
-	items := make([]dynamodb.TransactWriteItem, 0, len(fieldValues))
+	if len(fieldValues) == 0 {
+		return nil
+	}
+
+	if len(fieldValues) > c.hMaxFields(key) {
+		return ErrHTooManyFields
+	}
+
+	if c.countsCardinality(key) {
+		return c.hCountedMSET(key, fieldValues)
+	}
+
+	_, err = c.ddbClient.TransactWriteItemsRequest(&dynamodb.TransactWriteItemsInput{
+		TransactItems: c.hSetActions(key, fieldValues, nil),
+	}).Send(context.TODO())
+
+	return
+}
+
+// HMSETCHUNKED sets any number of fields, in chunks that each fit in a single HMSET transaction. Each chunk
+// is set atomically, but the whole is not: the chunks are set in order of field name, and if one fails an
+// HChunkError says which one, along with its fields.
+//
+// Cost is O(N) / 2N WCUs, where N is the number of fields.
+func (c Client) HMSETCHUNKED(key string, fieldValues map[string]Value) (err error) {
+	fields := make([]string, 0, len(fieldValues))
+	for field := range fieldValues {
+		fields = append(fields, field)
+	}
+
+	sort.Strings(fields)
+
+	chunkSize := c.hMaxFields(key)
+	chunks := (len(fields) + chunkSize - 1) / chunkSize
+
+	for chunk := 0; chunk < chunks; chunk++ {
+		chunkFields := fields[chunk*chunkSize:]
+		if len(chunkFields) > chunkSize {
+			chunkFields = chunkFields[:chunkSize]
+		}
+
+		chunkValues := make(map[string]Value, len(chunkFields))
+		for _, field := range chunkFields {
+			chunkValues[field] = fieldValues[field]
+		}
+
+		if err = c.HMSET(key, chunkValues); err != nil {
+			return HChunkError{Chunk: chunk, Chunks: chunks, Fields: chunkFields, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// hSetActions builds the transaction that sets the fields. If existing is given, every update is also
+// conditional on the field existing or not as it says.
+func (c Client) hSetActions(key string, fieldValues map[string]Value, existing map[string]bool) []dynamodb.TransactWriteItem {
+	items := make([]dynamodb.TransactWriteItem, 0, len(fieldValues)+1)
 
 	for field, v := range fieldValues {
 		builder := newExpresionBuilder()
 		builder.updateSET(vk, v)
+		c.hRemoveExpiry(&builder)
+
+		if existing != nil {
+			if existing[field] {
+				builder.addConditionExists(c.pk)
+			} else {
+				builder.addConditionNotExists(c.pk)
+			}
+		}
 
 		items = append(items, dynamodb.TransactWriteItem{
 			Update: &dynamodb.Update{
@@ -76,11 +180,40 @@ func (c Client) HMSET(key string, fieldValues map[string]Value) (err error) {
 		})
 	}
 
-	_, err = c.ddbClient.TransactWriteItemsRequest(&dynamodb.TransactWriteItemsInput{
-		TransactItems: items,
-	}).Send(context.TODO())
+	return items
+}
 
-	return
+// hCountedMSET reads which fields already exist, and sets the fields and adds the new ones to the
+// cardinality counter in one transaction that fails if any of them was created or deleted in the meantime.
+func (c Client) hCountedMSET(key string, fieldValues map[string]Value) error {
+	keys := make([]keyDef, 0, len(fieldValues))
+	for field := range fieldValues {
+		keys = append(keys, keyDef{pk: key, sk: field})
+	}
+
+	for retryCount := 0; retryCount < 5; retryCount++ {
+		items, err := c.StronglyConsistent().batchGet(keys)
+		if err != nil {
+			return err
+		}
+
+		existing := make(map[string]bool, len(items))
+		for _, item := range items {
+			existing[parseKey(item, c).sk] = true
+		}
+
+		actions := c.hSetActions(key, fieldValues, existing)
+		actions = append(actions, c.cardinalityActions(key, int64(len(fieldValues)-len(existing)))...)
+
+		_, err = c.ddbClient.TransactWriteItemsRequest(&dynamodb.TransactWriteItemsInput{
+			TransactItems: actions,
+		}).Send(context.TODO())
+		if !conditionFailureError(err) {
+			return err
+		}
+	}
+
+	return errors.New("too much contention")
 }
 
 func (c Client) HMGET(key string, fields ...string) (values map[string]ReturnValue, err error) {
@@ -93,8 +226,7 @@ func (c Client) HMGET(key string, fields ...string) (values map[string]ReturnVal
 				pk: key,
 				sk: field,
 			}.toAV(c),
-			ProjectionExpression: aws.String(strings.Join([]string{c.sk, vk}, ", ")),
-			TableName:            aws.String(c.table),
+			TableName: aws.String(c.table),
 		}}
 	}
 
@@ -104,8 +236,10 @@ func (c Client) HMGET(key string, fields ...string) (values map[string]ReturnVal
 
 	if err == nil {
 		for _, r := range resp.Responses {
-			pi := parseItem(r.Item, c)
-			values[pi.sk] = pi.val
+			if c.hLive(r.Item) {
+				pi := parseItem(r.Item, c)
+				values[pi.sk] = pi.val
+			}
 		}
 	}
 
@@ -113,6 +247,29 @@ func (c Client) HMGET(key string, fields ...string) (values map[string]ReturnVal
 }
 
 func (c Client) HDEL(key string, fields ...string) (deletedFields []string, err error) {
+	deleted, err := c.hDel(key, fields)
+
+	for _, field := range fields {
+		if _, ok := deleted[field]; ok {
+			deletedFields = append(deletedFields, field)
+		}
+	}
+
+	return
+}
+
+// HGETDEL deletes the fields and returns the values of the ones that existed.
+//
+// Cost is O(1) / 1 WCU for each field.
+//
+// Works similar to https://redis.io/commands/hgetdel
+func (c Client) HGETDEL(key string, fields ...string) (values map[string]ReturnValue, err error) {
+	return c.hDel(key, fields)
+}
+
+func (c Client) hDel(key string, fields []string) (values map[string]ReturnValue, err error) {
+	values = make(map[string]ReturnValue)
+
 	for _, field := range fields {
 		resp, err := c.ddbClient.DeleteItemRequest(&dynamodb.DeleteItemInput{
 			Key: keyDef{
@@ -123,11 +280,17 @@ func (c Client) HDEL(key string, fields ...string) (deletedFields []string, err
 			TableName:    aws.String(c.table),
 		}).Send(context.TODO())
 		if err != nil {
-			return deletedFields, err
+			return values, err
+		}
+
+		if c.hLive(resp.Attributes) {
+			values[field] = parseItem(resp.Attributes, c).val
 		}
 
 		if len(resp.Attributes) > 0 {
-			deletedFields = append(deletedFields, field)
+			if err = c.adjustCardinality(key, -1); err != nil {
+				return values, err
+			}
 		}
 	}
 
@@ -135,20 +298,9 @@ func (c Client) HDEL(key string, fields ...string) (deletedFields []string, err
 }
 
 func (c Client) HEXISTS(key string, field string) (exists bool, err error) {
-	resp, err := c.ddbClient.GetItemRequest(&dynamodb.GetItemInput{
-		ConsistentRead: aws.Bool(c.consistentReads),
-		Key: keyDef{
-			pk: key,
-			sk: field,
-		}.toAV(c),
-		ProjectionExpression: aws.String(strings.Join([]string{c.pk}, ", ")),
-		TableName:            aws.String(c.table),
-	}).Send(context.TODO())
-	if err == nil && len(resp.Item) > 0 {
-		exists = true
-	}
+	item, err := c.hField(key, field)
 
-	return
+	return item != nil, err
 }
 
 func (c Client) HGETALL(key string) (fieldValues map[string]ReturnValue, err error) {
@@ -175,8 +327,10 @@ func (c Client) HGETALL(key string) (fieldValues map[string]ReturnValue, err err
 		}
 
 		for _, item := range resp.Items {
-			parsedItem := parseItem(item, c)
-			fieldValues[parsedItem.sk] = parsedItem.val
+			if c.hLive(item) {
+				parsedItem := parseItem(item, c)
+				fieldValues[parsedItem.sk] = parsedItem.val
+			}
 		}
 
 		if len(resp.LastEvaluatedKey) > 0 {
@@ -199,31 +353,78 @@ func (c Client) HINCRBYFLOAT(key string, field string, delta float64) (after flo
 }
 
 func (c Client) hIncr(key string, field string, delta Value) (after ReturnValue, err error) {
-	builder := newExpresionBuilder()
-	builder.keys[vk] = struct{}{}
-	resp, err := c.ddbClient.UpdateItemRequest(&dynamodb.UpdateItemInput{
-		ExpressionAttributeNames: builder.expressionAttributeNames(),
-		ExpressionAttributeValues: map[string]dynamodb.AttributeValue{
-			":delta": delta.ToAV(),
-		},
-		Key:              keyDef{pk: key, sk: field}.toAV(c),
-		ReturnValues:     dynamodb.ReturnValueAllNew,
-		TableName:        aws.String(c.table),
-		UpdateExpression: aws.String("ADD #val :delta"),
-	}).Send(context.TODO())
+	counting := c.countsCardinality(key)
+	returnValues := dynamodb.ReturnValueAllNew
 
-	if err == nil {
-		after = ReturnValue{resp.UpdateItemOutput.Attributes[vk]}
+	if counting {
+		returnValues = dynamodb.ReturnValueAllOld
+	}
+
+	var resp *dynamodb.UpdateItemResponse
+
+	for retryCount := 0; retryCount < 5; retryCount++ {
+		builder := newExpresionBuilder()
+		builder.keys[vk] = struct{}{}
+		builder.values["delta"] = delta.ToAV()
+		builder.condition(fmt.Sprintf("(attribute_not_exists(#%v) OR #%v > :now)", c.ttl, c.ttl), c.ttl)
+		builder.values["now"] = IntValue{hNow()}.ToAV()
+
+		resp, err = c.ddbClient.UpdateItemRequest(&dynamodb.UpdateItemInput{
+			ConditionExpression:       builder.conditionExpression(),
+			ExpressionAttributeNames:  builder.expressionAttributeNames(),
+			ExpressionAttributeValues: builder.expressionAttributeValues(),
+			Key:                       keyDef{pk: key, sk: field}.toAV(c),
+			ReturnValues:              returnValues,
+			TableName:                 aws.String(c.table),
+			UpdateExpression:          aws.String("ADD #val :delta"),
+		}).Send(context.TODO())
+		if !conditionFailureError(err) {
+			break
+		}
+
+		// An expired field counts as missing, so it's deleted and the increment starts again from zero.
+		if err = c.hDeleteExpired(key, field); err != nil {
+			return
+		}
+
+		err = errors.New("too much contention")
+	}
+
+	if err != nil {
+		return
+	}
+
+	if !counting {
+		return ReturnValue{resp.UpdateItemOutput.Attributes[vk]}, nil
+	}
+
+	// The old values tell us whether the field was created, and the new value is their exact sum.
+	before := "0"
+	if oldAV, ok := resp.UpdateItemOutput.Attributes[vk]; ok {
+		before = aws.StringValue(oldAV.N)
+	}
+
+	after = ReturnValue{dynamodb.AttributeValue{N: aws.String(zAddNumbers(before, aws.StringValue(delta.ToAV().N)))}}
+
+	if len(resp.UpdateItemOutput.Attributes) == 0 {
+		err = c.adjustCardinality(key, 1)
 	}
 
 	return
 }
 
+// HINCRBY increments the integer in the field by delta, creating the field if it doesn't exist. Like INCRBY,
+// the existing value must be an integer, or ErrNotInteger is returned, and ErrOverflow is returned if the result
+// doesn't fit in an int64.
+//
+// Cost is O(1) / 1 WCU. Values last written by other commands, expired fields and failed increments cost an
+// extra 1 RCU + 1 WCU.
+//
+// Works similar to https://redis.io/commands/hincrby
 func (c Client) HINCRBY(key string, field string, delta int64) (after int64, err error) {
-	rv, err := c.hIncr(key, field, IntValue{delta})
-
-	if err == nil {
-		after = rv.Int()
+	after, created, err := c.incrInt(keyDef{pk: key, sk: field}, delta, c.hLive)
+	if err == nil && created {
+		err = c.adjustCardinality(key, 1)
 	}
 
 	return
@@ -237,6 +438,8 @@ func (c Client) HKEYS(key string) (keys []string, err error) {
 	for hasMoreResults {
 		builder := newExpresionBuilder()
 		builder.addConditionEquality(c.pk, StringValue{key})
+		builder.keys[c.sk] = struct{}{}
+		builder.keys[c.ttl] = struct{}{}
 
 		resp, err := c.ddbClient.QueryRequest(&dynamodb.QueryInput{
 			ConsistentRead:            aws.Bool(c.consistentReads),
@@ -245,7 +448,7 @@ func (c Client) HKEYS(key string) (keys []string, err error) {
 			ExpressionAttributeValues: builder.expressionAttributeValues(),
 			KeyConditionExpression:    builder.conditionExpression(),
 			TableName:                 aws.String(c.table),
-			ProjectionExpression:      aws.String(c.sk),
+			ProjectionExpression:      aws.String(fmt.Sprintf("#%v, #%v", c.sk, c.ttl)),
 			Select:                    dynamodb.SelectSpecificAttributes,
 		}).Send(context.TODO())
 
@@ -254,8 +457,10 @@ func (c Client) HKEYS(key string) (keys []string, err error) {
 		}
 
 		for _, item := range resp.Items {
-			parsedItem := parseItem(item, c)
-			keys = append(keys, parsedItem.sk)
+			if c.hLive(item) {
+				parsedItem := parseItem(item, c)
+				keys = append(keys, parsedItem.sk)
+			}
 		}
 
 		if len(resp.LastEvaluatedKey) > 0 {
@@ -279,7 +484,18 @@ func (c Client) HVALS(key string) (values []ReturnValue, err error) {
 	return
 }
 
+// HLEN returns the number of fields in the hash, leaving out expired fields. The count is kept in a counter if
+// CardinalityCounters is enabled – fields of those hashes can't be given an expiry, so the counter never includes
+// expired fields – and otherwise counted from every item in the hash.
+//
+// Cost is O(1) / 1 RCU with counters, otherwise O(size) / 1 RCU per 4KB of data counted.
+//
+// Works similar to https://redis.io/commands/hlen
 func (c Client) HLEN(key string) (count int64, err error) {
+	if c.countsCardinality(key) {
+		return c.cardinality(key)
+	}
+
 	hasMoreResults := true
 
 	var lastEvaluatedKey map[string]dynamodb.AttributeValue
@@ -287,12 +503,14 @@ func (c Client) HLEN(key string) (count int64, err error) {
 	for hasMoreResults {
 		builder := newExpresionBuilder()
 		builder.addConditionEquality(c.pk, StringValue{key})
+		filter := c.hLiveFilter(&builder)
 
 		resp, err := c.ddbClient.QueryRequest(&dynamodb.QueryInput{
 			ConsistentRead:            aws.Bool(c.consistentReads),
 			ExclusiveStartKey:         lastEvaluatedKey,
 			ExpressionAttributeNames:  builder.expressionAttributeNames(),
 			ExpressionAttributeValues: builder.expressionAttributeValues(),
+			FilterExpression:          filter,
 			KeyConditionExpression:    builder.conditionExpression(),
 			TableName:                 aws.String(c.table),
 			Select:                    dynamodb.SelectCount,
@@ -302,7 +520,8 @@ func (c Client) HLEN(key string) (count int64, err error) {
 			return count, err
 		}
 
-		count += aws.Int64Value(resp.ScannedCount)
+		// ScannedCount would include the items the filter left out.
+		count += aws.Int64Value(resp.Count)
 
 		if len(resp.LastEvaluatedKey) > 0 {
 			lastEvaluatedKey = resp.LastEvaluatedKey
@@ -317,9 +536,10 @@ func (c Client) HLEN(key string) (count int64, err error) {
 func (c Client) HSETNX(key string, field string, value Value) (ok bool, err error) {
 	builder := newExpresionBuilder()
 	builder.updateSET(vk, value)
-	builder.addConditionNotExists(c.pk)
+	c.hRemoveExpiry(&builder)
+	c.hConditionMissing(&builder)
 
-	_, err = c.ddbClient.UpdateItemRequest(&dynamodb.UpdateItemInput{
+	resp, err := c.ddbClient.UpdateItemRequest(&dynamodb.UpdateItemInput{
 		ConditionExpression:       builder.conditionExpression(),
 		ExpressionAttributeNames:  builder.expressionAttributeNames(),
 		ExpressionAttributeValues: builder.expressionAttributeValues(),
@@ -327,6 +547,7 @@ func (c Client) HSETNX(key string, field string, value Value) (ok bool, err erro
 			pk: key,
 			sk: field,
 		}.toAV(c),
+		ReturnValues:     dynamodb.ReturnValueAllOld,
 		TableName:        aws.String(c.table),
 		UpdateExpression: builder.updateExpression(),
 	}).Send(context.TODO())
@@ -339,5 +560,103 @@ func (c Client) HSETNX(key string, field string, value Value) (ok bool, err erro
 		return false, err
 	}
 
-	return true, nil
+	if len(resp.Attributes) > 0 {
+		return true, nil
+	}
+
+	return true, c.adjustCardinality(key, 1)
+}
+
+// hDeleteExpired deletes the field only if it has expired.
+func (c Client) hDeleteExpired(key string, field string) error {
+	builder := newExpresionBuilder()
+	builder.condition(fmt.Sprintf("#%v <= :now", c.ttl), c.ttl)
+	builder.values["now"] = IntValue{hNow()}.ToAV()
+
+	_, err := c.ddbClient.DeleteItemRequest(&dynamodb.DeleteItemInput{
+		ConditionExpression:       builder.conditionExpression(),
+		ExpressionAttributeNames:  builder.expressionAttributeNames(),
+		ExpressionAttributeValues: builder.expressionAttributeValues(),
+		Key:                       keyDef{pk: key, sk: field}.toAV(c),
+		TableName:                 aws.String(c.table),
+	}).Send(context.TODO())
+
+	if conditionFailureError(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return c.adjustCardinality(key, -1)
+}
+
+// HSTRLEN returns the length of the value of the field, or 0 if it doesn't exist. Numbers are measured in
+// their decimal form, and bytes by their count.
+//
+// Cost is O(1) / 1 RCU.
+//
+// Works similar to https://redis.io/commands/hstrlen
+func (c Client) HSTRLEN(key string, field string) (length int64, err error) {
+	val, err := c.HGET(key, field)
+	if err != nil {
+		return
+	}
+
+	av := val.ToAV()
+
+	switch {
+	case av.S != nil:
+		length = int64(len(aws.StringValue(av.S)))
+	case av.N != nil:
+		length = int64(len(aws.StringValue(av.N)))
+	default:
+		length = int64(len(av.B))
+	}
+
+	return
+}
+
+// HField is a field of a hash along with its value.
+type HField struct {
+	Field string
+	Value ReturnValue
+}
+
+// HRANDFIELD returns up to count distinct random fields of the hash, along with their values. If count is
+// negative, exactly -count fields are returned, possibly repeating. The values are always returned, as with
+// WITHVALUES, since they are read anyway.
+//
+// Cost is O(N) / N RCUs, where N is the size of the hash, since every field is read.
+//
+// Works similar to https://redis.io/commands/hrandfield
+func (c Client) HRANDFIELD(key string, count int64) (fields []HField, err error) {
+	all, err := c.HGETALL(key)
+	if err != nil || len(all) == 0 {
+		return nil, err
+	}
+
+	allFields := make([]HField, 0, len(all))
+	for field, value := range all {
+		allFields = append(allFields, HField{Field: field, Value: value})
+	}
+
+	if count < 0 {
+		for i := int64(0); i < -count; i++ {
+			fields = append(fields, allFields[rand.Intn(len(allFields))])
+		}
+
+		return
+	}
+
+	rand.Shuffle(len(allFields), func(i, j int) {
+		allFields[i], allFields[j] = allFields[j], allFields[i]
+	})
+
+	if count < int64(len(allFields)) {
+		allFields = allFields[:count]
+	}
+
+	return allFields, nil
 }
@@ -1,7 +1,10 @@
 package redimo
 
 import (
+	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -140,3 +143,247 @@ func TestHashCounters(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, int64(42), v.Int())
 }
+
+func TestHashCardinality(t *testing.T) {
+	c := newClient(t).CardinalityCounters()
+
+	_, err := c.HSET("k1", map[string]Value{"f1": StringValue{"v1"}, "f2": StringValue{"v2"}})
+	assert.NoError(t, err)
+
+	_, err = c.HSET("k1", map[string]Value{"f1": StringValue{"v1"}})
+	assert.NoError(t, err)
+
+	assert.NoError(t, c.HMSET("k1", map[string]Value{"f2": StringValue{"v2"}, "f3": StringValue{"v3"}}))
+
+	ok, err := c.HSETNX("k1", "f4", StringValue{"v4"})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = c.HSETNX("k1", "f4", StringValue{"v4"})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	after, err := c.HINCRBY("k1", "f5", 3)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), after)
+
+	after, err = c.HINCRBY("k1", "f5", 4)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), after)
+
+	count, err := c.HLEN("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), count)
+
+	_, err = c.HDEL("k1", "f1", "f2", "nosuchfield")
+	assert.NoError(t, err)
+
+	count, err = c.HLEN("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	_, err = c.HEXPIRE("k1", time.Second, Flags{}, "f3")
+	assert.Equal(t, ErrHExpiryWithCounters, err)
+
+	_, err = c.HGETEX("k1", time.Second, "f3")
+	assert.Equal(t, ErrHExpiryWithCounters, err)
+
+	values, err := c.HGETEX("k1", 0, "f3")
+	assert.NoError(t, err)
+	assert.Equal(t, "v3", values["f3"].String())
+
+	results, err := c.HEXPIRE("k1", 0, Flags{}, "f3")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{HFieldExpiredNow}, results)
+
+	count, err = c.HLEN("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	count, err = c.HLEN("nosuchkey")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestHashChunks(t *testing.T) {
+	c := newClient(t)
+
+	fieldValues := make(map[string]Value)
+	for i := 0; i < 250; i++ {
+		fieldValues[fmt.Sprintf("f%03d", i)] = IntValue{int64(i)}
+	}
+
+	err := c.HMSET("k1", fieldValues)
+	assert.Equal(t, ErrHTooManyFields, err)
+
+	count, err := c.HLEN("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	assert.NoError(t, c.HMSETCHUNKED("k1", fieldValues))
+
+	count, err = c.HLEN("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(250), count)
+
+	fieldValues[""] = StringValue{"v"}
+
+	err = c.HMSETCHUNKED("k2", fieldValues)
+
+	var chunkErr HChunkError
+
+	assert.True(t, errors.As(err, &chunkErr))
+	assert.Equal(t, 0, chunkErr.Chunk)
+	assert.Equal(t, 3, chunkErr.Chunks)
+	assert.Contains(t, chunkErr.Fields, "")
+}
+
+func TestHashNewCommands(t *testing.T) {
+	c := newClient(t)
+
+	_, err := c.HSET("k1", map[string]Value{"f1": StringValue{"hello"}, "f2": IntValue{12345}, "f3": BytesValue{[]byte{1, 2}}})
+	assert.NoError(t, err)
+
+	for field, expectedLength := range map[string]int64{"f1": 5, "f2": 5, "f3": 2, "nosuchfield": 0} {
+		length, err := c.HSTRLEN("k1", field)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedLength, length, field)
+	}
+
+	fields, err := c.HRANDFIELD("k1", 2)
+	assert.NoError(t, err)
+	assert.Len(t, fields, 2)
+	assert.NotEqual(t, fields[0].Field, fields[1].Field)
+
+	fields, err = c.HRANDFIELD("k1", 10)
+	assert.NoError(t, err)
+	assert.Len(t, fields, 3)
+
+	for _, field := range fields {
+		val, err := c.HGET("k1", field.Field)
+		assert.NoError(t, err)
+		assert.True(t, val.Equals(field.Value))
+	}
+
+	fields, err = c.HRANDFIELD("k1", -7)
+	assert.NoError(t, err)
+	assert.Len(t, fields, 7)
+
+	fields, err = c.HRANDFIELD("nosuchkey", 3)
+	assert.NoError(t, err)
+	assert.Empty(t, fields)
+
+	values, err := c.HGETDEL("k1", "f1", "nosuchfield")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]ReturnValue{"f1": {StringValue{"hello"}.ToAV()}}, values)
+
+	exists, err := c.HEXISTS("k1", "f1")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestHashFieldExpiry(t *testing.T) {
+	c := newClient(t)
+
+	_, err := c.HSET("k1", map[string]Value{"f1": StringValue{"v1"}, "f2": StringValue{"v2"}, "f3": StringValue{"v3"}})
+	assert.NoError(t, err)
+
+	results, err := c.HEXPIRE("k1", time.Hour, Flags{}, "f1", "nosuchfield")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{HExpirySet, HFieldMissing}, results)
+
+	results, err = c.HEXPIRE("k1", 2*time.Hour, Flags{IfNotExists}, "f1", "f2")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{HExpiryNotSet, HExpirySet}, results)
+
+	results, err = c.HEXPIRE("k1", time.Hour/2, Flags{IfGreaterThan}, "f1", "f3")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{HExpiryNotSet, HExpiryNotSet}, results)
+
+	results, err = c.HEXPIRE("k1", time.Hour/2, Flags{IfLessThan}, "f1")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{HExpirySet}, results)
+
+	ttls, err := c.HTTL("k1", "f1", "f2", "f3", "nosuchfield")
+	assert.NoError(t, err)
+	assert.InDelta(t, 1800, ttls[0], 5)
+	assert.InDelta(t, 7200, ttls[1], 5)
+	assert.Equal(t, []int64{HNoExpiry, HFieldMissing}, ttls[2:])
+
+	results, err = c.HPERSIST("k1", "f2", "f3", "nosuchfield")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{HExpiryRemoved, HNoExpiry, HFieldMissing}, results)
+
+	values, err := c.HGETEX("k1", time.Second, "f2", "f3", "nosuchfield")
+	assert.NoError(t, err)
+	assert.Len(t, values, 2)
+
+	// A sub-second ttl is rounded up, so the field is still there right after it's set.
+	_, err = c.HSET("k1", map[string]Value{"f4": StringValue{"v4"}})
+	assert.NoError(t, err)
+
+	results, err = c.HEXPIRE("k1", time.Millisecond, Flags{}, "f4")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{HExpirySet}, results)
+
+	exists, err := c.HEXISTS("k1", "f4")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	time.Sleep(2500 * time.Millisecond)
+
+	val, err := c.HGET("k1", "f2")
+	assert.NoError(t, err)
+	assert.True(t, val.Empty())
+
+	all, err := c.HGETALL("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]ReturnValue{"f1": {StringValue{"v1"}.ToAV()}}, all)
+
+	keys, err := c.HKEYS("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"f1"}, keys)
+
+	ok, err := c.HSETNX("k1", "f2", StringValue{"new"})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	after, err := c.HINCRBY("k1", "f3", 5)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), after)
+
+	ttls, err = c.HTTL("k1", "f2", "f3")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{HNoExpiry, HNoExpiry}, ttls)
+
+	results, err = c.HEXPIRE("k1", 0, Flags{}, "f1")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{HFieldExpiredNow}, results)
+
+	exists, err = c.HEXISTS("k1", "f1")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestHashLengthFiltering(t *testing.T) {
+	c := newClient(t)
+
+	_, err := c.HSET("k1", map[string]Value{"f1": StringValue{"v1"}, "f2": StringValue{"v2"}, "f3": StringValue{"v3"}})
+	assert.NoError(t, err)
+
+	_, err = c.HSET("k1", map[string]Value{"_redimo/f4": StringValue{"v4"}})
+	assert.NoError(t, err)
+
+	_, err = c.HEXPIRE("k1", time.Second, Flags{}, "f2")
+	assert.NoError(t, err)
+
+	time.Sleep(2500 * time.Millisecond)
+
+	count, err := c.HLEN("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	keys, err := c.HKEYS("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"_redimo/f4", "f1", "f3"}, keys)
+}
@@ -0,0 +1,300 @@
+package redimo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// Hash fields can expire individually, as in Redis 7.4. The expiry time is kept in the field's item, in the
+// attribute set with TTLAttribute, as a Unix timestamp in seconds. DynamoDB TTL deletes expired items some
+// time after they expire – up to a few days later – so every hash command treats an expired field as missing
+// until then. Setting a field with HSET, HMSET or HSETNX removes its expiry, as in Redis.
+//
+// Fields deleted by DynamoDB TTL would never be subtracted from cardinality counters, so HEXPIRE and HGETEX
+// refuse to set an expiry on the fields of hashes that use them.
+
+// ErrHExpiryWithCounters is returned by HEXPIRE and HGETEX when asked to set an expiry on a field of a hash whose
+// cardinality is counted with CardinalityCounters.
+var ErrHExpiryWithCounters = errors.New("fields of hashes with cardinality counters can't expire")
+
+// The results of HEXPIRE, HPERSIST and HTTL for each field, as in Redis.
+const (
+	HFieldMissing    int64 = -2
+	HNoExpiry        int64 = -1
+	HExpiryNotSet    int64 = 0
+	HExpirySet       int64 = 1
+	HExpiryRemoved   int64 = 1
+	HFieldExpiredNow int64 = 2
+)
+
+func hNow() int64 {
+	return time.Now().Unix()
+}
+
+// hExpiresAt is the time in whole seconds that a field given the ttl expires at. It's rounded up, so that a
+// field given a positive ttl is still live when the command returns.
+func hExpiresAt(ttl time.Duration) int64 {
+	return (time.Now().Add(ttl).UnixNano() + int64(time.Second) - 1) / int64(time.Second)
+}
+
+// hLive is false for missing items and expired fields.
+func (c Client) hLive(item map[string]dynamodb.AttributeValue) bool {
+	if len(item) == 0 {
+		return false
+	}
+
+	expiresAt, ok := item[c.ttl]
+
+	return !ok || ReturnValue{expiresAt}.Int() > hNow()
+}
+
+// hLiveFilter returns a filter expression for the items that hLive accepts, adding its names and values to
+// the query's builder.
+func (c Client) hLiveFilter(builder *expressionBuilder) *string {
+	builder.keys[c.ttl] = struct{}{}
+	builder.values["now"] = IntValue{hNow()}.ToAV()
+
+	return aws.String(fmt.Sprintf("attribute_not_exists(#%v) OR #%v > :now", c.ttl, c.ttl))
+}
+
+// hConditionLive adds a condition that the field exists and hasn't expired.
+func (c Client) hConditionLive(builder *expressionBuilder) {
+	builder.addConditionExists(c.pk)
+	builder.condition(fmt.Sprintf("(attribute_not_exists(#%v) OR #%v > :now)", c.ttl, c.ttl), c.ttl)
+	builder.values["now"] = IntValue{hNow()}.ToAV()
+}
+
+// hConditionMissing adds a condition that the field doesn't exist or has expired.
+func (c Client) hConditionMissing(builder *expressionBuilder) {
+	builder.condition(fmt.Sprintf("(attribute_not_exists(#%v) OR #%v <= :now)", c.pk, c.ttl), c.pk, c.ttl)
+	builder.values["now"] = IntValue{hNow()}.ToAV()
+}
+
+func (c Client) hRemoveExpiry(builder *expressionBuilder) {
+	builder.clauses["REMOVE"] = append(builder.clauses["REMOVE"], "#"+c.ttl)
+	builder.keys[c.ttl] = struct{}{}
+}
+
+// hField reads the field's item, returning nil if it's missing or expired.
+func (c Client) hField(key string, field string) (item map[string]dynamodb.AttributeValue, err error) {
+	resp, err := c.ddbClient.GetItemRequest(&dynamodb.GetItemInput{
+		ConsistentRead: aws.Bool(c.consistentReads),
+		Key:            keyDef{pk: key, sk: field}.toAV(c),
+		TableName:      aws.String(c.table),
+	}).Send(context.TODO())
+	if err != nil || !c.hLive(resp.Item) {
+		return nil, err
+	}
+
+	return resp.Item, nil
+}
+
+// HEXPIRE sets the fields to expire after ttl, which is rounded up to whole seconds. The flags work as in Redis:
+// IfNotExists (NX) only sets an expiry on fields without one, IfAlreadyExists (XX) only on fields with one, and
+// IfGreaterThan (GT) and IfLessThan (LT) only if the new expiry is later or earlier than the current one, with no
+// expiry counting as later than any.
+//
+// For each field the result is HFieldMissing, HExpiryNotSet if the flags prevented it, HExpirySet, or
+// HFieldExpiredNow if ttl was zero or less and the field was deleted. A positive ttl returns
+// ErrHExpiryWithCounters if the hash uses cardinality counters.
+//
+// Cost is O(1) / 1 WCU for each field, plus 1 RCU for fields whose expiry wasn't set.
+//
+// Works similar to https://redis.io/commands/hexpire
+func (c Client) HEXPIRE(key string, ttl time.Duration, flags Flags, fields ...string) (results []int64, err error) {
+	if ttl > 0 && c.countsCardinality(key) {
+		return nil, ErrHExpiryWithCounters
+	}
+
+	expiresAt := hExpiresAt(ttl)
+
+	for _, field := range fields {
+		result, err := c.hExpire(key, field, ttl, expiresAt, flags)
+		if err != nil {
+			return results, err
+		}
+
+		results = append(results, result)
+	}
+
+	return
+}
+
+func (c Client) hExpire(key string, field string, ttl time.Duration, expiresAt int64, flags Flags) (result int64, err error) {
+	if ttl <= 0 {
+		deleted, err := c.HGETDEL(key, field)
+		if err != nil || len(deleted) == 0 {
+			return HFieldMissing, err
+		}
+
+		return HFieldExpiredNow, nil
+	}
+
+	builder := newExpresionBuilder()
+	builder.updateSET(c.ttl, IntValue{expiresAt})
+	c.hConditionLive(&builder)
+
+	switch {
+	case flags.has(IfNotExists):
+		builder.addConditionNotExists(c.ttl)
+	case flags.has(IfAlreadyExists):
+		builder.addConditionExists(c.ttl)
+	}
+
+	switch {
+	case flags.has(IfGreaterThan):
+		builder.condition(fmt.Sprintf("(attribute_exists(#%v) AND #%v < :%v)", c.ttl, c.ttl, c.ttl), c.ttl)
+	case flags.has(IfLessThan):
+		builder.condition(fmt.Sprintf("(attribute_not_exists(#%v) OR #%v > :%v)", c.ttl, c.ttl, c.ttl), c.ttl)
+	}
+
+	_, err = c.ddbClient.UpdateItemRequest(&dynamodb.UpdateItemInput{
+		ConditionExpression:       builder.conditionExpression(),
+		ExpressionAttributeNames:  builder.expressionAttributeNames(),
+		ExpressionAttributeValues: builder.expressionAttributeValues(),
+		Key:                       keyDef{pk: key, sk: field}.toAV(c),
+		TableName:                 aws.String(c.table),
+		UpdateExpression:          builder.updateExpression(),
+	}).Send(context.TODO())
+	if err == nil {
+		return HExpirySet, nil
+	}
+
+	if !conditionFailureError(err) {
+		return
+	}
+
+	item, err := c.StronglyConsistent().hField(key, field)
+	if err != nil || item == nil {
+		return HFieldMissing, err
+	}
+
+	return HExpiryNotSet, nil
+}
+
+// HTTL returns the number of seconds until each field expires, HNoExpiry for fields that don't, or
+// HFieldMissing.
+//
+// Cost is O(1) / 1 RCU for each field.
+//
+// Works similar to https://redis.io/commands/httl
+func (c Client) HTTL(key string, fields ...string) (ttls []int64, err error) {
+	for _, field := range fields {
+		item, err := c.hField(key, field)
+		if err != nil {
+			return ttls, err
+		}
+
+		expiresAt, hasExpiry := item[c.ttl]
+
+		switch {
+		case item == nil:
+			ttls = append(ttls, HFieldMissing)
+		case !hasExpiry:
+			ttls = append(ttls, HNoExpiry)
+		default:
+			ttls = append(ttls, ReturnValue{expiresAt}.Int()-hNow())
+		}
+	}
+
+	return
+}
+
+// HPERSIST removes the expiry of the fields. For each field the result is HExpiryRemoved, HNoExpiry if it had
+// none, or HFieldMissing.
+//
+// Cost is O(1) / 1 WCU for each field, plus 1 RCU for fields without an expiry.
+//
+// Works similar to https://redis.io/commands/hpersist
+func (c Client) HPERSIST(key string, fields ...string) (results []int64, err error) {
+	for _, field := range fields {
+		builder := newExpresionBuilder()
+		c.hRemoveExpiry(&builder)
+		builder.condition(fmt.Sprintf("#%v > :now", c.ttl), c.ttl)
+		builder.values["now"] = IntValue{hNow()}.ToAV()
+
+		_, err = c.ddbClient.UpdateItemRequest(&dynamodb.UpdateItemInput{
+			ConditionExpression:       builder.conditionExpression(),
+			ExpressionAttributeNames:  builder.expressionAttributeNames(),
+			ExpressionAttributeValues: builder.expressionAttributeValues(),
+			Key:                       keyDef{pk: key, sk: field}.toAV(c),
+			TableName:                 aws.String(c.table),
+			UpdateExpression:          builder.updateExpression(),
+		}).Send(context.TODO())
+
+		switch {
+		case err == nil:
+			results = append(results, HExpiryRemoved)
+			continue
+		case !conditionFailureError(err):
+			return results, err
+		}
+
+		item, err := c.StronglyConsistent().hField(key, field)
+		if err != nil {
+			return results, err
+		}
+
+		if item == nil {
+			results = append(results, HFieldMissing)
+		} else {
+			results = append(results, HNoExpiry)
+		}
+	}
+
+	return results, nil
+}
+
+// HGETEX returns the values of the fields that exist, like HMGET, and sets them to expire after ttl. A ttl of
+// zero or less removes their expiry instead, like the PERSIST option. A positive ttl returns
+// ErrHExpiryWithCounters if the hash uses cardinality counters.
+//
+// Cost is O(1) / 1 WCU for each field.
+//
+// Works similar to https://redis.io/commands/hgetex
+func (c Client) HGETEX(key string, ttl time.Duration, fields ...string) (values map[string]ReturnValue, err error) {
+	if ttl > 0 && c.countsCardinality(key) {
+		return nil, ErrHExpiryWithCounters
+	}
+
+	values = make(map[string]ReturnValue)
+	expiresAt := hExpiresAt(ttl)
+
+	for _, field := range fields {
+		builder := newExpresionBuilder()
+		c.hConditionLive(&builder)
+
+		if ttl > 0 {
+			builder.updateSET(c.ttl, IntValue{expiresAt})
+		} else {
+			c.hRemoveExpiry(&builder)
+		}
+
+		resp, err := c.ddbClient.UpdateItemRequest(&dynamodb.UpdateItemInput{
+			ConditionExpression:       builder.conditionExpression(),
+			ExpressionAttributeNames:  builder.expressionAttributeNames(),
+			ExpressionAttributeValues: builder.expressionAttributeValues(),
+			Key:                       keyDef{pk: key, sk: field}.toAV(c),
+			ReturnValues:              dynamodb.ReturnValueAllNew,
+			TableName:                 aws.String(c.table),
+			UpdateExpression:          builder.updateExpression(),
+		}).Send(context.TODO())
+
+		if conditionFailureError(err) {
+			continue
+		}
+
+		if err != nil {
+			return values, err
+		}
+
+		values[field] = ReturnValue{resp.Attributes[vk]}
+	}
+
+	return values, nil
+}
@@ -1,6 +1,7 @@
 package redimo
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
@@ -18,6 +19,10 @@ type Client struct {
 	pk              string
 	sk              string
 	skN             string
+	ttl             string
+	zBucketWidth    float64
+
+	cardinalityCounters bool
 }
 
 func (c Client) EventuallyConsistent() Client {
@@ -40,11 +45,44 @@ func (c Client) Attributes(pk string, sk string, skN string) Client {
 	return c
 }
 
+// TTLAttribute sets the name of the attribute that holds the expiry time of hash fields, which should be
+// configured as the table's DynamoDB TTL attribute so that expired fields are eventually deleted. The default
+// is "ttl".
+func (c Client) TTLAttribute(ttl string) Client {
+	c.ttl = ttl
+	return c
+}
+
 func (c Client) StronglyConsistent() Client {
 	c.consistentReads = true
 	return c
 }
 
+// ZRankIndex maintains a count of the members of every sorted set per score range of the given width,
+// so that ZRANK, ZREVRANK and ZCOUNT read the counters and at most two ranges of members instead of
+// every member below (or above) the score. ZADD, ZINCRBY and ZREM become transactions that read
+// the current score first, so writes cost roughly twice as much. Pick a width that keeps the number of
+// members per range in the low hundreds. A width of 0 disables the index.
+//
+// The index must be enabled (with the same width) on every client that writes to the sorted sets
+// from the time they are created, otherwise the counters will be wrong.
+func (c Client) ZRankIndex(bucketWidth float64) Client {
+	c.zBucketWidth = bucketWidth
+	return c
+}
+
+// CardinalityCounters maintains a count of the fields of every hash and the members of every set and sorted set,
+// so that HLEN, SCARD and ZCARD read a single item instead of counting the whole key. Every write that adds or
+// removes fields or members also writes to the counter, and HMSET can then set at most 99 fields at once, since
+// the counter takes up one item of its transaction.
+//
+// The counters must be enabled on every client that writes to the keys from the time they are created, otherwise
+// they will be wrong.
+func (c Client) CardinalityCounters() Client {
+	c.cardinalityCounters = true
+	return c
+}
+
 func NewClient(service *dynamodb.Client) Client {
 	return Client{
 		ddbClient:       service,
@@ -54,6 +92,7 @@ func NewClient(service *dynamodb.Client) Client {
 		pk:              "pk",
 		sk:              "sk",
 		skN:             "skN",
+		ttl:             "ttl",
 	}
 }
 
@@ -211,6 +250,87 @@ func parseItem(avm map[string]dynamodb.AttributeValue, c Client) (item itemDef)
 	return
 }
 
+// internalPrefix starts the keys of the partitions redimo keeps for its own bookkeeping.
+const internalPrefix = "_redimo/"
+
+const maxBatchGetKeys = 100
+
+// batchGet fetches the items at the given keys with BatchGetItem, 100 keys per request, retrying any keys
+// left unprocessed. Keys that don't exist are missing from the result, which is in no particular order.
+func (c Client) batchGet(keys []keyDef) (items []map[string]dynamodb.AttributeValue, err error) {
+	seen := make(map[keyDef]struct{}, len(keys))
+	uniqueKeys := make([]keyDef, 0, len(keys))
+
+	for _, key := range keys {
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			uniqueKeys = append(uniqueKeys, key)
+		}
+	}
+
+	for start := 0; start < len(uniqueKeys); start += maxBatchGetKeys {
+		end := start + maxBatchGetKeys
+		if end > len(uniqueKeys) {
+			end = len(uniqueKeys)
+		}
+
+		requestKeys := make([]map[string]dynamodb.AttributeValue, 0, end-start)
+		for _, key := range uniqueKeys[start:end] {
+			requestKeys = append(requestKeys, key.toAV(c))
+		}
+
+		for len(requestKeys) > 0 {
+			resp, err := c.ddbClient.BatchGetItemRequest(&dynamodb.BatchGetItemInput{
+				RequestItems: map[string]dynamodb.KeysAndAttributes{
+					c.table: {
+						ConsistentRead: aws.Bool(c.consistentReads),
+						Keys:           requestKeys,
+					},
+				},
+			}).Send(context.TODO())
+			if err != nil {
+				return items, err
+			}
+
+			items = append(items, resp.Responses[c.table]...)
+			requestKeys = resp.UnprocessedKeys[c.table].Keys
+		}
+	}
+
+	return
+}
+
+const maxBatchWriteItems = 25
+
+// maxTransactionItems is the number of items a single TransactWriteItems or TransactGetItems call can hold.
+const maxTransactionItems = 100
+
+// batchWrite applies the write requests with BatchWriteItem, 25 requests per call, retrying any requests
+// left unprocessed. The writes are not atomic, and a single item can't be written twice in a batch.
+func (c Client) batchWrite(requests []dynamodb.WriteRequest) error {
+	for start := 0; start < len(requests); start += maxBatchWriteItems {
+		end := start + maxBatchWriteItems
+		if end > len(requests) {
+			end = len(requests)
+		}
+
+		pending := requests[start:end]
+
+		for len(pending) > 0 {
+			resp, err := c.ddbClient.BatchWriteItemRequest(&dynamodb.BatchWriteItemInput{
+				RequestItems: map[string][]dynamodb.WriteRequest{c.table: pending},
+			}).Send(context.TODO())
+			if err != nil {
+				return err
+			}
+
+			pending = resp.UnprocessedItems[c.table]
+		}
+	}
+
+	return nil
+}
+
 type Flag string
 
 const (
@@ -218,6 +338,9 @@ const (
 	Unconditionally      = None
 	IfAlreadyExists Flag = "XX"
 	IfNotExists     Flag = "NX"
+	IfGreaterThan   Flag = "GT"
+	IfLessThan      Flag = "LT"
+	ReturnChanged   Flag = "CH"
 )
 
 type Flags []Flag
@@ -249,3 +372,66 @@ func conditionFailureError(err error) bool {
 
 	return false
 }
+
+// partitionIterator reads the items of a partition in sort key order, a page at a time.
+type partitionIterator struct {
+	c       Client
+	key     string
+	from    string
+	items   []map[string]dynamodb.AttributeValue
+	lastKey map[string]dynamodb.AttributeValue
+	done    bool
+}
+
+func (c Client) iterate(key string) *partitionIterator {
+	return &partitionIterator{c: c, key: key}
+}
+
+func (it *partitionIterator) next() (item map[string]dynamodb.AttributeValue, ok bool, err error) {
+	for len(it.items) == 0 {
+		if it.done {
+			return nil, false, nil
+		}
+
+		builder := newExpresionBuilder()
+		builder.addConditionEquality(it.c.pk, StringValue{it.key})
+
+		if it.from != "" {
+			builder.condition(fmt.Sprintf("#%v >= :from", it.c.sk), it.c.sk)
+			builder.values["from"] = StringValue{it.from}.ToAV()
+		}
+
+		resp, err := it.c.ddbClient.QueryRequest(&dynamodb.QueryInput{
+			ConsistentRead:            aws.Bool(it.c.consistentReads),
+			ExclusiveStartKey:         it.lastKey,
+			ExpressionAttributeNames:  builder.expressionAttributeNames(),
+			ExpressionAttributeValues: builder.expressionAttributeValues(),
+			KeyConditionExpression:    builder.conditionExpression(),
+			TableName:                 aws.String(it.c.table),
+		}).Send(context.TODO())
+		if err != nil {
+			return nil, false, err
+		}
+
+		it.items = resp.Items
+		it.lastKey = resp.LastEvaluatedKey
+		it.done = len(resp.LastEvaluatedKey) == 0
+	}
+
+	item, it.items = it.items[0], it.items[1:]
+
+	return item, true, nil
+}
+
+// seek skips ahead to the first item with a sort key at or after the given one. If it isn't in the current
+// page, the partition is queried again from that sort key, instead of reading every page in between.
+func (it *partitionIterator) seek(sk string) {
+	for len(it.items) > 0 && parseKey(it.items[0], it.c).sk < sk {
+		it.items = it.items[1:]
+	}
+
+	if len(it.items) == 0 && !it.done {
+		it.from = sk
+		it.lastKey = nil
+	}
+}
@@ -2,6 +2,8 @@ package redimo
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math/rand"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -28,6 +30,54 @@ func (sm setMember) keyAV(c Client) map[string]dynamodb.AttributeValue {
 	return av
 }
 
+// setCursor walks the members of a set in order, a page at a time, for the streaming set operations.
+type setCursor struct {
+	it     *partitionIterator
+	member string
+	ok     bool
+}
+
+func (c Client) sCursor(key string) (cursor *setCursor, err error) {
+	cursor = &setCursor{it: c.iterate(key)}
+	err = cursor.advance()
+
+	return
+}
+
+func (sc *setCursor) advance() error {
+	item, ok, err := sc.it.next()
+	if err == nil {
+		sc.ok = ok
+		sc.member = parseKey(item, sc.it.c).sk
+	}
+
+	return err
+}
+
+// advanceTo moves the cursor to the first member at or after the given one.
+func (sc *setCursor) advanceTo(member string) error {
+	if !sc.ok || sc.member >= member {
+		return nil
+	}
+
+	sc.it.seek(member)
+
+	return sc.advance()
+}
+
+func (c Client) sCursors(keys []string) (cursors []*setCursor, err error) {
+	for _, key := range keys {
+		cursor, err := c.sCursor(key)
+		if err != nil {
+			return cursors, err
+		}
+
+		cursors = append(cursors, cursor)
+	}
+
+	return
+}
+
 // SADD adds the given string members to the set at the given key.
 //
 // Returns that members that were actually added and did not already exist in the set.
@@ -48,102 +98,183 @@ func (c Client) SADD(key string, members ...string) (addedMembers []string, err
 
 		if len(resp.Attributes) == 0 {
 			addedMembers = append(addedMembers, member)
+
+			if err = c.adjustCardinality(key, 1); err != nil {
+				return addedMembers, err
+			}
 		}
 	}
 
 	return
 }
 
-// SCARD returns the cardinality (the number of elements) in the set at key.
+// SCARD returns the cardinality (the number of elements) in the set at key. The count is kept in a counter if
+// CardinalityCounters is enabled, and otherwise counted from every member of the set.
 //
-// Cost is O(size) / 1 WCU per 4KB of data counted.
+// Cost is O(1) / 1 RCU with CardinalityCounters, otherwise O(size) / 1 RCU per 4KB of data counted.
 //
 // Works similar to https://redis.io/commands/scard
 func (c Client) SCARD(key string) (count int64, err error) {
 	return c.HLEN(key)
 }
 
+// SDIFF returns the members of the set at key that are not in any of the sets at subtractKeys, in order.
+// The sets are read together in member order, a page at a time, so only a page of each set is held in
+// memory besides the result.
+//
+// Cost is O(N) / N RCUs, where N is the total size of all the sets.
+//
+// Works similar to https://redis.io/commands/sdiff
 func (c Client) SDIFF(key string, subtractKeys ...string) (members []string, err error) {
-	memberSet := make(map[string]struct{})
-	startingList, err := c.SMEMBERS(key)
-
+	cursors, err := c.sCursors(append([]string{key}, subtractKeys...))
 	if err != nil {
 		return
 	}
 
-	for _, member := range startingList {
-		memberSet[member] = struct{}{}
-	}
+	source, others := cursors[0], cursors[1:]
 
-	for _, otherKey := range subtractKeys {
-		otherList, err := c.SMEMBERS(otherKey)
-		if err != nil {
-			return members, err
+	for source.ok {
+		subtracted := false
+
+		for _, other := range others {
+			if err = other.advanceTo(source.member); err != nil {
+				return
+			}
+
+			if other.ok && other.member == source.member {
+				subtracted = true
+				break
+			}
 		}
 
-		for _, member := range otherList {
-			delete(memberSet, member)
+		if !subtracted {
+			members = append(members, source.member)
 		}
-	}
 
-	for member := range memberSet {
-		members = append(members, member)
+		if err = source.advance(); err != nil {
+			return
+		}
 	}
 
 	return
 }
 
+// SDIFFSTORE stores the result of SDIFF at destinationKey, replacing whatever the set there contained, and
+// returns its size. The result is built in full before the destination is touched, and then swapped in: it is
+// compared with the current destination in member order, and only the members that are missing are added and
+// the ones that don't belong are removed, each on the condition that it hasn't been added or removed
+// concurrently. If that's 100 changes or fewer (99 with cardinality counters) they are made in a single
+// transaction, so readers see either the old set or the new one. Larger changes are made in several
+// transactions, adding the new members before removing the stale ones, so a concurrent reader never misses a
+// member of the result but may briefly see stale members alongside it.
+//
+// Cost is that of SDIFF, plus O(N) / N RCUs to read the destination and 2 WCUs for each member changed.
+//
+// Works similar to https://redis.io/commands/sdiffstore
 func (c Client) SDIFFSTORE(destinationKey string, sourceKey string, subtractKeys ...string) (count int64, err error) {
 	members, err := c.SDIFF(sourceKey, subtractKeys...)
 	if err == nil {
-		_, err = c.SADD(destinationKey, members...)
+		err = c.sReplace(destinationKey, members)
 	}
 
 	return int64(len(members)), err
 }
 
+// SINTER returns the members present in every one of the given sets, in order. The sets are read together
+// in member order, and whenever one set is behind the others it skips ahead with a new query instead of reading
+// the members in between, so intersecting a small set with large ones reads little more than the small set.
+//
+// Cost is O(N) / N RCUs in the worst case, where N is the total size of all the sets.
+//
+// Works similar to https://redis.io/commands/sinter
 func (c Client) SINTER(key string, otherKeys ...string) (members []string, err error) {
-	memberSet := make(map[string]struct{})
-	startingList, err := c.SMEMBERS(key)
+	err = c.sIntersect(append([]string{key}, otherKeys...), func(member string) bool {
+		members = append(members, member)
+		return true
+	})
 
-	if err != nil {
-		return
+	return
+}
+
+// SINTERCARD returns the number of members present in every one of the given sets, stopping as soon as the
+// count reaches limit. A limit of 0 means no limit.
+//
+// Cost is the same as SINTER, up to the point where the limit is reached.
+//
+// Works similar to https://redis.io/commands/sintercard
+func (c Client) SINTERCARD(limit int64, keys ...string) (count int64, err error) {
+	err = c.sIntersect(keys, func(string) bool {
+		count++
+		return limit == 0 || count < limit
+	})
+
+	return
+}
+
+// sIntersect calls found with each member present in every set, in order, until it returns false.
+func (c Client) sIntersect(keys []string, found func(member string) bool) error {
+	if len(keys) == 0 {
+		return nil
 	}
 
-	for _, member := range startingList {
-		memberSet[member] = struct{}{}
+	cursors, err := c.sCursors(keys)
+	if err != nil {
+		return err
 	}
 
-	for _, otherKey := range otherKeys {
-		otherList, err := c.SMEMBERS(otherKey)
-		if err != nil {
-			return members, err
-		}
+	for {
+		target := ""
 
-		otherSet := make(map[string]struct{})
+		for _, cursor := range cursors {
+			if !cursor.ok {
+				return nil
+			}
 
-		for _, member := range otherList {
-			otherSet[member] = struct{}{}
+			if cursor.member > target {
+				target = cursor.member
+			}
 		}
 
-		for member := range memberSet {
-			if _, ok := otherSet[member]; !ok {
-				delete(memberSet, member)
+		matched := true
+
+		for _, cursor := range cursors {
+			if err := cursor.advanceTo(target); err != nil {
+				return err
+			}
+
+			if !cursor.ok {
+				return nil
+			}
+
+			if cursor.member != target {
+				matched = false
 			}
 		}
-	}
 
-	for member := range memberSet {
-		members = append(members, member)
-	}
+		if !matched {
+			continue
+		}
 
-	return
+		if !found(target) {
+			return nil
+		}
+
+		for _, cursor := range cursors {
+			if err := cursor.advance(); err != nil {
+				return err
+			}
+		}
+	}
 }
 
+// SINTERSTORE stores the result of SINTER at destinationKey, replacing whatever the set there contained, and
+// returns its size. See SDIFFSTORE for how the destination is replaced.
+//
+// Works similar to https://redis.io/commands/sinterstore
 func (c Client) SINTERSTORE(destinationKey string, sourceKey string, otherKeys ...string) (count int64, err error) {
 	members, err := c.SINTER(sourceKey, otherKeys...)
 	if err == nil {
-		_, err = c.SADD(destinationKey, members...)
+		err = c.sReplace(destinationKey, members)
 	}
 
 	return int64(len(members)), err
@@ -162,6 +293,37 @@ func (c Client) SISMEMBER(key string, member string) (ok bool, err error) {
 	return true, nil
 }
 
+// SMISMEMBER reports whether each of the given members is in the set at key, in the same order, reading
+// them with BatchGetItem instead of one request per member.
+//
+// Cost is O(N) / N RCUs, where N is the number of members asked about.
+//
+// Works similar to https://redis.io/commands/smismember
+func (c Client) SMISMEMBER(key string, members ...string) (found []bool, err error) {
+	keys := make([]keyDef, len(members))
+	for i, member := range members {
+		keys[i] = keyDef{pk: key, sk: member}
+	}
+
+	items, err := c.batchGet(keys)
+	if err != nil {
+		return
+	}
+
+	present := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		present[parseKey(item, c).sk] = struct{}{}
+	}
+
+	found = make([]bool, len(members))
+
+	for i, member := range members {
+		_, found[i] = present[member]
+	}
+
+	return
+}
+
 func (c Client) SMEMBERS(key string) (members []string, err error) {
 	hasMoreResults := true
 
@@ -200,27 +362,12 @@ func (c Client) SMEMBERS(key string) (members []string, err error) {
 }
 
 func (c Client) SMOVE(sourceKey string, destinationKey string, member string) (ok bool, err error) {
-	builder := newExpresionBuilder()
-	builder.addConditionExists(c.pk)
+	if c.countsCardinality(sourceKey) || c.countsCardinality(destinationKey) {
+		return c.sCountedMove(sourceKey, destinationKey, member)
+	}
 
 	_, err = c.ddbClient.TransactWriteItemsRequest(&dynamodb.TransactWriteItemsInput{
-		TransactItems: []dynamodb.TransactWriteItem{
-			{
-				Delete: &dynamodb.Delete{
-					ConditionExpression:       builder.conditionExpression(),
-					ExpressionAttributeNames:  builder.expressionAttributeNames(),
-					ExpressionAttributeValues: builder.expressionAttributeValues(),
-					Key:                       setMember{pk: sourceKey, sk: member}.keyAV(c),
-					TableName:                 aws.String(c.table),
-				},
-			},
-			{
-				Put: &dynamodb.Put{
-					Item:      setMember{pk: destinationKey, sk: member}.toAV(c),
-					TableName: aws.String(c.table),
-				},
-			},
-		},
+		TransactItems: c.sMoveActions(sourceKey, destinationKey, member, nil),
 	}).Send(context.TODO())
 
 	if conditionFailureError(err) {
@@ -234,39 +381,202 @@ func (c Client) SMOVE(sourceKey string, destinationKey string, member string) (o
 	return true, nil
 }
 
+// sMoveActions builds the transaction that moves the member. If alreadyAtDestination is given, the
+// put is also conditional on the member being in the destination set or not as it says.
+func (c Client) sMoveActions(sourceKey string, destinationKey string, member string, alreadyAtDestination *bool) []dynamodb.TransactWriteItem {
+	deleteBuilder := newExpresionBuilder()
+	deleteBuilder.addConditionExists(c.pk)
+
+	put := &dynamodb.Put{
+		Item:      setMember{pk: destinationKey, sk: member}.toAV(c),
+		TableName: aws.String(c.table),
+	}
+
+	if alreadyAtDestination != nil {
+		putBuilder := newExpresionBuilder()
+
+		if *alreadyAtDestination {
+			putBuilder.addConditionExists(c.pk)
+		} else {
+			putBuilder.addConditionNotExists(c.pk)
+		}
+
+		put.ConditionExpression = putBuilder.conditionExpression()
+		put.ExpressionAttributeNames = putBuilder.expressionAttributeNames()
+	}
+
+	return []dynamodb.TransactWriteItem{
+		{
+			Delete: &dynamodb.Delete{
+				ConditionExpression:       deleteBuilder.conditionExpression(),
+				ExpressionAttributeNames:  deleteBuilder.expressionAttributeNames(),
+				ExpressionAttributeValues: deleteBuilder.expressionAttributeValues(),
+				Key:                       setMember{pk: sourceKey, sk: member}.keyAV(c),
+				TableName:                 aws.String(c.table),
+			},
+		},
+		{
+			Put: put,
+		},
+	}
+}
+
+// sCountedMove checks whether the member is already in the destination set, and moves it along with
+// the changes to both cardinality counters in one transaction that fails if either side changed meanwhile.
+func (c Client) sCountedMove(sourceKey string, destinationKey string, member string) (ok bool, err error) {
+	for retryCount := 0; retryCount < 5; retryCount++ {
+		isSourceMember, err := c.StronglyConsistent().SISMEMBER(sourceKey, member)
+		if err != nil || !isSourceMember {
+			return false, err
+		}
+
+		if sourceKey == destinationKey {
+			return true, nil
+		}
+
+		alreadyAtDestination, err := c.StronglyConsistent().SISMEMBER(destinationKey, member)
+		if err != nil {
+			return false, err
+		}
+
+		actions := c.sMoveActions(sourceKey, destinationKey, member, &alreadyAtDestination)
+		actions = append(actions, c.cardinalityActions(sourceKey, -1)...)
+
+		if !alreadyAtDestination {
+			actions = append(actions, c.cardinalityActions(destinationKey, 1)...)
+		}
+
+		_, err = c.ddbClient.TransactWriteItemsRequest(&dynamodb.TransactWriteItemsInput{
+			TransactItems: actions,
+		}).Send(context.TODO())
+		if err == nil {
+			return true, nil
+		}
+
+		if !conditionFailureError(err) {
+			return false, err
+		}
+	}
+
+	return false, errors.New("too much contention")
+}
+
+// SPOP removes and returns up to count random members from the set. Each member is deleted on its own, and
+// only the members this call actually deleted are returned, so concurrent callers never get the same member.
+// Members taken by someone else are replaced with new random picks, until count members are popped or the set
+// is empty.
+//
+// Cost is O(count) / count RCUs and WCUs, plus retries under contention.
+//
+// Works similar to https://redis.io/commands/spop
 func (c Client) SPOP(key string, count int64) (members []string, err error) {
-	members, err = c.SRANDMEMBER(key, count)
-	if err == nil {
-		_, err = c.SREM(key, members...)
+	for int64(len(members)) < count {
+		candidates, err := c.SRANDMEMBER(key, count-int64(len(members)))
+		if err != nil || len(candidates) == 0 {
+			return members, err
+		}
+
+		poppedMembers, err := c.SREM(key, candidates...)
+		members = append(members, poppedMembers...)
+
+		if err != nil {
+			return members, err
+		}
 	}
 
 	return
 }
 
+// SRANDMEMBER returns up to count distinct random members of the set. If count is negative, exactly -count
+// members are returned, and the same member may be returned more than once.
+//
+// Every member is stored with a random number in skN. A positive count reads the members following a random
+// pivot on the skN index, wrapping around to the start if the end is reached, and a negative count reads one
+// member after a fresh pivot for every pick.
+//
+// Cost is O(|count|) / 1 RCU per 4KB read for positive counts and per pick for negative counts.
+//
+// Works similar to https://redis.io/commands/srandmember
 func (c Client) SRANDMEMBER(key string, count int64) (members []string, err error) {
 	if count < 0 {
-		count = -count
+		for i := int64(0); i < -count; i++ {
+			picked, err := c.sRandomMembers(key, 1)
+			if err != nil || len(picked) == 0 {
+				return members, err
+			}
+
+			members = append(members, picked...)
+		}
+
+		return
+	}
+
+	members, err = c.sRandomMembers(key, count)
+
+	rand.Shuffle(len(members), func(i, j int) {
+		members[i], members[j] = members[j], members[i]
+	})
+
+	return
+}
+
+// sRandomMembers reads up to count consecutive members on the skN index, starting from a random pivot.
+func (c Client) sRandomMembers(key string, count int64) (members []string, err error) {
+	if count == 0 {
+		return
 	}
 
+	pivot := rand.Int63()
+
+	members, err = c.sMembersFromPivot(key, pivot, count, true)
+	if err != nil || int64(len(members)) == count {
+		return
+	}
+
+	wrappedMembers, err := c.sMembersFromPivot(key, pivot, count-int64(len(members)), false)
+
+	return append(members, wrappedMembers...), err
+}
+
+func (c Client) sMembersFromPivot(key string, pivot int64, count int64, fromPivot bool) (members []string, err error) {
 	builder := newExpresionBuilder()
 	builder.addConditionEquality(c.pk, StringValue{key})
 
-	resp, err := c.ddbClient.QueryRequest(&dynamodb.QueryInput{
-		ConsistentRead:            aws.Bool(c.consistentReads),
-		ExpressionAttributeNames:  builder.expressionAttributeNames(),
-		ExpressionAttributeValues: builder.expressionAttributeValues(),
-		KeyConditionExpression:    builder.conditionExpression(),
-		Limit:                     aws.Int64(count),
-		TableName:                 aws.String(c.table),
-	}).Send(context.TODO())
-
-	if err != nil {
-		return members, err
+	operator := "<"
+	if fromPivot {
+		operator = ">="
 	}
 
-	for _, item := range resp.Items {
-		parsedItem := parseItem(item, c)
-		members = append(members, parsedItem.sk)
+	builder.condition(fmt.Sprintf("#%v %v :pivot", c.skN, operator), c.skN)
+
+	builder.values["pivot"] = IntValue{pivot}.ToAV()
+
+	var lastEvaluatedKey map[string]dynamodb.AttributeValue
+
+	for int64(len(members)) < count {
+		resp, err := c.ddbClient.QueryRequest(&dynamodb.QueryInput{
+			ConsistentRead:            aws.Bool(c.consistentReads),
+			ExclusiveStartKey:         lastEvaluatedKey,
+			ExpressionAttributeNames:  builder.expressionAttributeNames(),
+			ExpressionAttributeValues: builder.expressionAttributeValues(),
+			IndexName:                 aws.String(c.index),
+			KeyConditionExpression:    builder.conditionExpression(),
+			Limit:                     aws.Int64(count - int64(len(members))),
+			TableName:                 aws.String(c.table),
+		}).Send(context.TODO())
+		if err != nil {
+			return members, err
+		}
+
+		for _, item := range resp.Items {
+			members = append(members, parseKey(item, c).sk)
+		}
+
+		if len(resp.LastEvaluatedKey) == 0 {
+			break
+		}
+
+		lastEvaluatedKey = resp.LastEvaluatedKey
 	}
 
 	return
@@ -288,38 +598,203 @@ func (c Client) SREM(key string, members ...string) (removedMembers []string, er
 
 		if len(resp.Attributes) > 0 {
 			removedMembers = append(removedMembers, member)
+
+			if err = c.adjustCardinality(key, -1); err != nil {
+				return removedMembers, err
+			}
 		}
 	}
 
 	return
 }
 
+// SUNION returns the members present in any of the given sets, in order. The sets are read together in
+// member order, a page at a time, so only a page of each set is held in memory besides the result.
+//
+// Cost is O(N) / N RCUs, where N is the total size of all the sets.
+//
+// Works similar to https://redis.io/commands/sunion
 func (c Client) SUNION(keys ...string) (members []string, err error) {
-	memberSet := make(map[string]struct{})
+	cursors, err := c.sCursors(keys)
+	if err != nil {
+		return
+	}
 
-	for _, key := range keys {
-		setMembers, err := c.SMEMBERS(key)
-		if err != nil {
-			return members, err
+	for {
+		smallest, found := "", false
+
+		for _, cursor := range cursors {
+			if cursor.ok && (!found || cursor.member < smallest) {
+				smallest, found = cursor.member, true
+			}
 		}
 
-		for _, member := range setMembers {
-			memberSet[member] = struct{}{}
+		if !found {
+			return
 		}
-	}
 
-	for member := range memberSet {
-		members = append(members, member)
-	}
+		members = append(members, smallest)
 
-	return
+		for _, cursor := range cursors {
+			if cursor.ok && cursor.member == smallest {
+				if err = cursor.advance(); err != nil {
+					return
+				}
+			}
+		}
+	}
 }
 
+// SUNIONSTORE stores the result of SUNION at destinationKey, replacing whatever the set there contained, and
+// returns its size. See SDIFFSTORE for how the destination is replaced.
+//
+// Works similar to https://redis.io/commands/sunionstore
 func (c Client) SUNIONSTORE(destinationKey string, sourceKeys ...string) (count int64, err error) {
 	members, err := c.SUNION(sourceKeys...)
 	if err == nil {
-		_, err = c.SADD(destinationKey, members...)
+		err = c.sReplace(destinationKey, members)
 	}
 
 	return int64(len(members)), err
 }
+
+// sReplace makes the set at key contain exactly the given members, which must be in order. The result is
+// already built in full, so it is swapped in with the fewest writes: in a single transaction if the changes fit,
+// and otherwise by sSwapIn.
+func (c Client) sReplace(key string, members []string) error {
+	for retryCount := 0; retryCount < 5; retryCount++ {
+		added, removed, err := c.sChanges(key, members)
+		if err != nil {
+			return err
+		}
+
+		switch changes := len(added) + len(removed); {
+		case changes == 0:
+			return nil
+		case changes+len(c.cardinalityActions(key, int64(len(added)-len(removed)))) <= maxTransactionItems:
+			err = c.sApplyChanges(key, added, removed)
+		default:
+			err = c.sSwapIn(key, added, removed)
+		}
+
+		if !conditionFailureError(err) {
+			return err
+		}
+	}
+
+	return errors.New("too much contention")
+}
+
+// sSwapIn applies changes that don't fit in a single transaction, in transactions of up to 100 items: first
+// the additions, and then the removals, so a concurrent reader never misses a member of the result but may
+// briefly see stale members alongside it. Each transaction adjusts the cardinality counter by its own changes.
+func (c Client) sSwapIn(key string, added []string, removed []string) error {
+	chunkSize := maxTransactionItems - 1
+
+	for len(added) > 0 {
+		chunk := added
+		if len(chunk) > chunkSize {
+			chunk = chunk[:chunkSize]
+		}
+
+		if err := c.sApplyChanges(key, chunk, nil); err != nil {
+			return err
+		}
+
+		added = added[len(chunk):]
+	}
+
+	for len(removed) > 0 {
+		chunk := removed
+		if len(chunk) > chunkSize {
+			chunk = chunk[:chunkSize]
+		}
+
+		if err := c.sApplyChanges(key, nil, chunk); err != nil {
+			return err
+		}
+
+		removed = removed[len(chunk):]
+	}
+
+	return nil
+}
+
+// sApplyChanges adds and removes the members in a single transaction, on the condition that the members added
+// don't exist yet and the members removed still do.
+func (c Client) sApplyChanges(key string, added []string, removed []string) error {
+	actions := c.cardinalityActions(key, int64(len(added)-len(removed)))
+
+	for _, member := range added {
+		builder := newExpresionBuilder()
+		builder.addConditionNotExists(c.pk)
+
+		actions = append(actions, dynamodb.TransactWriteItem{
+			Put: &dynamodb.Put{
+				ConditionExpression:      builder.conditionExpression(),
+				ExpressionAttributeNames: builder.expressionAttributeNames(),
+				Item:                     setMember{pk: key, sk: member}.toAV(c),
+				TableName:                aws.String(c.table),
+			},
+		})
+	}
+
+	for _, member := range removed {
+		builder := newExpresionBuilder()
+		builder.addConditionExists(c.pk)
+
+		actions = append(actions, dynamodb.TransactWriteItem{
+			Delete: &dynamodb.Delete{
+				ConditionExpression:      builder.conditionExpression(),
+				ExpressionAttributeNames: builder.expressionAttributeNames(),
+				Key:                      setMember{pk: key, sk: member}.keyAV(c),
+				TableName:                aws.String(c.table),
+			},
+		})
+	}
+
+	_, err := c.ddbClient.TransactWriteItemsRequest(&dynamodb.TransactWriteItemsInput{
+		TransactItems: actions,
+	}).Send(context.TODO())
+
+	return err
+}
+
+// sChanges compares the set at key with the given members, which must be in order, and returns the members to
+// add and remove.
+func (c Client) sChanges(key string, members []string) (added []string, removed []string, err error) {
+	destination, err := c.sCursor(key)
+	if err != nil {
+		return
+	}
+
+	for _, member := range members {
+		for destination.ok && destination.member < member {
+			removed = append(removed, destination.member)
+
+			if err = destination.advance(); err != nil {
+				return
+			}
+		}
+
+		if destination.ok && destination.member == member {
+			if err = destination.advance(); err != nil {
+				return
+			}
+
+			continue
+		}
+
+		added = append(added, member)
+	}
+
+	for destination.ok {
+		removed = append(removed, destination.member)
+
+		if err = destination.advance(); err != nil {
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,174 @@
+package redimo
+
+import (
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// SBulkOptions are the options of SADDBULK and SREMBULK. Concurrency is the number of BatchWriteItem
+// requests of 25 members each that are in flight at once, with 4 used if it's zero or less. Report asks for
+// the members that were actually added or removed, which costs an extra BatchGetItem read per batch.
+type SBulkOptions struct {
+	Concurrency int
+	Report      bool
+}
+
+const sBulkDefaultConcurrency = 4
+
+// SADDBULK adds many members to the set at key, 25 at a time with BatchWriteItem, instead of one request per
+// member as SADD does. Members left unprocessed by DynamoDB are retried. The batches are not atomic – if an
+// error is returned some of the members may have been added.
+//
+// If options.Report is set, the members that were not in the set are returned. They are found by reading each
+// batch just before it is written, so a member added concurrently by another client may be reported by both.
+//
+// A read like that can't keep a cardinality counter exact, so when CardinalityCounters is enabled each batch is
+// instead written one member at a time, as SADD does, and the counter is adjusted only for the writes that
+// actually added a member – concurrent writers of the same members can't make it drift. The members are then
+// always reported exactly, and the concurrent batches still save round trips.
+//
+// Cost is O(N) / N WCUs, plus N RCUs when reporting, where N is the number of members. With cardinality
+// counters, it is N WCUs plus 1 WCU for each member added.
+//
+// Works similar to https://redis.io/commands/sadd
+func (c Client) SADDBULK(key string, members []string, options SBulkOptions) (addedMembers []string, err error) {
+	return c.sBulk(key, members, options, true)
+}
+
+// SREMBULK removes many members from the set at key, 25 at a time with BatchWriteItem. It works like SADDBULK,
+// and if options.Report is set returns the members that were in the set.
+//
+// Cost is O(N) / N WCUs, plus N RCUs when reporting, where N is the number of members. With cardinality
+// counters, it is N WCUs plus 1 WCU for each member removed.
+//
+// Works similar to https://redis.io/commands/srem
+func (c Client) SREMBULK(key string, members []string, options SBulkOptions) (removedMembers []string, err error) {
+	return c.sBulk(key, members, options, false)
+}
+
+func (c Client) sBulk(key string, members []string, options SBulkOptions, add bool) (changedMembers []string, err error) {
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = sBulkDefaultConcurrency
+	}
+
+	// A batch can't write the same item twice.
+	seen := make(map[string]struct{}, len(members))
+	uniqueMembers := make([]string, 0, len(members))
+
+	for _, member := range members {
+		if _, ok := seen[member]; !ok {
+			seen[member] = struct{}{}
+			uniqueMembers = append(uniqueMembers, member)
+		}
+	}
+
+	batches := make(chan []string)
+	done := make(chan struct{})
+
+	var (
+		mutex    sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for batch := range batches {
+				changed, err := c.sBulkBatch(key, batch, options.Report, add)
+
+				mutex.Lock()
+				changedMembers = append(changedMembers, changed...)
+
+				if err != nil && firstErr == nil {
+					firstErr = err
+					close(done)
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for start := 0; start < len(uniqueMembers); start += maxBatchWriteItems {
+		end := start + maxBatchWriteItems
+		if end > len(uniqueMembers) {
+			end = len(uniqueMembers)
+		}
+
+		select {
+		case batches <- uniqueMembers[start:end]:
+		case <-done:
+			break feed
+		}
+	}
+
+	close(batches)
+	wg.Wait()
+
+	if firstErr != nil {
+		return changedMembers, firstErr
+	}
+
+	if !options.Report {
+		changedMembers = nil
+	}
+
+	return
+}
+
+// sBulkBatch writes a single batch, first reading which of the members are in the set if asked to report. Keys
+// with cardinality counters are written member by member instead, to count only the writes that changed the set.
+func (c Client) sBulkBatch(key string, members []string, report bool, add bool) (changedMembers []string, err error) {
+	if c.countsCardinality(key) {
+		if add {
+			return c.SADD(key, members...)
+		}
+
+		return c.SREM(key, members...)
+	}
+
+	if report {
+		keys := make([]keyDef, len(members))
+		for i, member := range members {
+			keys[i] = keyDef{pk: key, sk: member}
+		}
+
+		items, err := c.StronglyConsistent().batchGet(keys)
+		if err != nil {
+			return nil, err
+		}
+
+		present := make(map[string]struct{}, len(items))
+		for _, item := range items {
+			present[parseKey(item, c).sk] = struct{}{}
+		}
+
+		for _, member := range members {
+			if _, ok := present[member]; ok != add {
+				changedMembers = append(changedMembers, member)
+			}
+		}
+	}
+
+	requests := make([]dynamodb.WriteRequest, len(members))
+
+	for i, member := range members {
+		if add {
+			requests[i] = dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: setMember{pk: key, sk: member}.toAV(c)}}
+		} else {
+			requests[i] = dynamodb.WriteRequest{DeleteRequest: &dynamodb.DeleteRequest{Key: setMember{pk: key, sk: member}.keyAV(c)}}
+		}
+	}
+
+	if err = c.batchWrite(requests); err != nil {
+		return nil, err
+	}
+
+	return changedMembers, nil
+}
@@ -1,6 +1,8 @@
 package redimo
 
 import (
+	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -177,3 +179,359 @@ func TestSetModifiers(t *testing.T) {
 	assert.NoError(t, err)
 	assert.ElementsMatch(t, []string{"m1"}, members)
 }
+
+func TestSetRandomness(t *testing.T) {
+	c := newClient(t)
+
+	var all []string
+	for i := 0; i < 20; i++ {
+		all = append(all, fmt.Sprintf("m%02d", i))
+	}
+
+	_, err := c.SADD("s1", all...)
+	assert.NoError(t, err)
+
+	seen := make(map[string]struct{})
+
+	for i := 0; i < 40; i++ {
+		members, err := c.SRANDMEMBER("s1", 1)
+		assert.NoError(t, err)
+		assert.Len(t, members, 1)
+		assert.Subset(t, all, members)
+
+		seen[members[0]] = struct{}{}
+	}
+
+	assert.Greater(t, len(seen), 1)
+
+	members, err := c.SRANDMEMBER("s1", 15)
+	assert.NoError(t, err)
+	assert.Len(t, members, 15)
+
+	unique := make(map[string]struct{})
+	for _, member := range members {
+		unique[member] = struct{}{}
+	}
+
+	assert.Len(t, unique, 15)
+
+	members, err = c.SRANDMEMBER("s1", 50)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, all, members)
+
+	members, err = c.SRANDMEMBER("s1", -50)
+	assert.NoError(t, err)
+	assert.Len(t, members, 50)
+	assert.Subset(t, all, members)
+
+	members, err = c.SRANDMEMBER("nope", -5)
+	assert.NoError(t, err)
+	assert.Empty(t, members)
+}
+
+func TestSetConcurrentPops(t *testing.T) {
+	c := newClient(t)
+
+	var all []string
+	for i := 0; i < 60; i++ {
+		all = append(all, fmt.Sprintf("m%02d", i))
+	}
+
+	_, err := c.SADD("s1", all...)
+	assert.NoError(t, err)
+
+	var (
+		wg     sync.WaitGroup
+		mutex  sync.Mutex
+		popped []string
+	)
+
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for {
+				members, err := c.SPOP("s1", 4)
+				assert.NoError(t, err)
+
+				if len(members) == 0 {
+					return
+				}
+
+				mutex.Lock()
+				popped = append(popped, members...)
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	assert.ElementsMatch(t, all, popped)
+}
+
+func TestSetCardinality(t *testing.T) {
+	c := newClient(t).CardinalityCounters()
+
+	_, err := c.SADD("s1", "m1", "m2", "m3")
+	assert.NoError(t, err)
+
+	_, err = c.SADD("s1", "m1", "m4")
+	assert.NoError(t, err)
+
+	_, err = c.SADD("s2", "m1")
+	assert.NoError(t, err)
+
+	ok, err := c.SMOVE("s1", "s2", "m1")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = c.SMOVE("s1", "s2", "m2")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = c.SMOVE("s1", "s2", "nosuchmember")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = c.SREM("s1", "m3", "nosuchmember")
+	assert.NoError(t, err)
+
+	count, err := c.SCARD("s1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	count, err = c.SCARD("s2")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestSetStreamingOperations(t *testing.T) {
+	c := newClient(t)
+
+	var evens, threes []string
+
+	for i := 0; i < 300; i++ {
+		member := fmt.Sprintf("m%03d", i)
+		if i%2 == 0 {
+			evens = append(evens, member)
+		}
+
+		if i%3 == 0 {
+			threes = append(threes, member)
+		}
+	}
+
+	_, err := c.SADD("evens", evens...)
+	assert.NoError(t, err)
+	_, err = c.SADD("threes", threes...)
+	assert.NoError(t, err)
+	_, err = c.SADD("few", "m000", "m150", "m299", "zzz")
+	assert.NoError(t, err)
+
+	var sixes, oddThrees []string
+
+	for i := 0; i < 300; i += 3 {
+		if i%2 == 0 {
+			sixes = append(sixes, fmt.Sprintf("m%03d", i))
+		} else {
+			oddThrees = append(oddThrees, fmt.Sprintf("m%03d", i))
+		}
+	}
+
+	intersection, err := c.SINTER("evens", "threes")
+	assert.NoError(t, err)
+	assert.Equal(t, sixes, intersection)
+
+	intersection, err = c.SINTER("few", "evens", "threes")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"m000", "m150"}, intersection)
+
+	diff, err := c.SDIFF("threes", "evens")
+	assert.NoError(t, err)
+	assert.Equal(t, oddThrees, diff)
+
+	union, err := c.SUNION("evens", "threes", "few")
+	assert.NoError(t, err)
+	assert.Len(t, union, 202)
+	assert.Equal(t, "zzz", union[len(union)-1])
+
+	count, err := c.SINTERCARD(0, "evens", "threes")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(sixes)), count)
+
+	count, err = c.SINTERCARD(10, "evens", "threes")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), count)
+
+	count, err = c.SINTERCARD(0, "evens", "nosuchset")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	found, err := c.SMISMEMBER("few", "m150", "m151", "zzz", "m150")
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true, false, true, true}, found)
+}
+
+func TestSetStoresReplaceDestination(t *testing.T) {
+	c := newClient(t).CardinalityCounters()
+
+	_, err := c.SADD("s1", "m1", "m2", "m3")
+	assert.NoError(t, err)
+	_, err = c.SADD("s2", "m3", "m4")
+	assert.NoError(t, err)
+	_, err = c.SADD("dst", "m1", "stale")
+	assert.NoError(t, err)
+
+	count, err := c.SUNIONSTORE("dst", "s1", "s2")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), count)
+
+	members, err := c.SMEMBERS("dst")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"m1", "m2", "m3", "m4"}, members)
+
+	count, err = c.SINTERSTORE("dst", "s1", "s2")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	members, err = c.SMEMBERS("dst")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"m3"}, members)
+
+	var many []string
+	for i := 0; i < 60; i++ {
+		many = append(many, fmt.Sprintf("n%02d", i))
+	}
+
+	_, err = c.SADD("many", many...)
+	assert.NoError(t, err)
+
+	count, err = c.SDIFFSTORE("dst", "many", "s1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(60), count)
+
+	members, err = c.SMEMBERS("dst")
+	assert.NoError(t, err)
+	assert.Equal(t, many, members)
+
+	count, err = c.SDIFFSTORE("dst", "s1", "s2")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	members, err = c.SMEMBERS("dst")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"m1", "m2"}, members)
+
+	count, err = c.SCARD("dst")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	for i := 60; i < 120; i++ {
+		many = append(many, fmt.Sprintf("n%02d", i))
+	}
+
+	_, err = c.SADD("many", many[60:]...)
+	assert.NoError(t, err)
+
+	// 122 changes don't fit in a transaction, so they are swapped in over several.
+	count, err = c.SUNIONSTORE("dst", "many")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(120), count)
+
+	members, err = c.SMEMBERS("dst")
+	assert.NoError(t, err)
+	assert.Equal(t, many, members)
+
+	count, err = c.SCARD("dst")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(120), count)
+
+	count, err = c.SDIFFSTORE("dst", "s1", "s2")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	members, err = c.SMEMBERS("dst")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"m1", "m2"}, members)
+
+	count, err = c.SCARD("dst")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestSetBulk(t *testing.T) {
+	c := newClient(t).CardinalityCounters()
+
+	var members []string
+	for i := 0; i < 500; i++ {
+		members = append(members, fmt.Sprintf("m%03d", i))
+	}
+
+	_, err := c.SADD("s1", "m000", "m499")
+	assert.NoError(t, err)
+
+	added, err := c.SADDBULK("s1", append(members, "m001"), SBulkOptions{Report: true})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, members[1:499], added)
+
+	added, err = c.SADDBULK("s2", members, SBulkOptions{Concurrency: 1})
+	assert.NoError(t, err)
+	assert.Empty(t, added)
+
+	storedMembers, err := c.SMEMBERS("s2")
+	assert.NoError(t, err)
+	assert.Equal(t, members, storedMembers)
+
+	removed, err := c.SREMBULK("s1", append(members[:100], "nosuchmember"), SBulkOptions{Report: true, Concurrency: 8})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, members[:100], removed)
+
+	count, err := c.SCARD("s1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(400), count)
+
+	// Concurrent bulk writes of the same members must not count any of them twice.
+	var wg sync.WaitGroup
+
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := c.SADDBULK("s3", members, SBulkOptions{})
+			assert.NoError(t, err)
+		}()
+	}
+
+	wg.Wait()
+
+	count, err = c.SCARD("s3")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(500), count)
+}
+
+func TestSetCardinalityFiltering(t *testing.T) {
+	c := newClient(t)
+
+	_, err := c.SADD("s1", "m1", "m2", "_redimo/m3")
+	assert.NoError(t, err)
+
+	members, err := c.SMEMBERS("s1")
+	assert.NoError(t, err)
+
+	count, err := c.SCARD("s1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(members)), count)
+	assert.Equal(t, int64(3), count)
+
+	_, err = c.ZADD("z1", map[string]float64{"m1": 1, "m2": 2, "_redimo/m3": 3}, Flags{})
+	assert.NoError(t, err)
+
+	count, err = c.ZCARD("z1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+}
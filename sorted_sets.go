@@ -2,8 +2,11 @@ package redimo
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
+	"math/rand"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -37,17 +40,26 @@ var accumulators = map[ZAggregation]func(float64, float64) float64{
 	},
 }
 
+// ZMember is a member of a sorted set along with its score. Range and pop operations return
+// ordered slices of ZMember, with members of equal score ordered lexicographically.
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
 type rangeCap interface {
 	Value
 	present() bool
+	excludes(member string) bool
 }
+
 type zScore struct {
 	score float64
 }
 
 func (zs zScore) ToAV() (av dynamodb.AttributeValue) {
 	if zs.present() {
-		av.N = aws.String(strconv.FormatFloat(zs.score, 'G', 17, 64))
+		av.N = aws.String(zFormatScore(zs.score))
 	}
 
 	return
@@ -57,6 +69,10 @@ func (zs zScore) present() bool {
 	return !math.IsInf(zs.score, +1) && !math.IsInf(zs.score, -1)
 }
 
+func (zs zScore) excludes(member string) bool {
+	return false
+}
+
 type zLex struct {
 	lex string
 }
@@ -73,23 +89,242 @@ func (zl zLex) present() bool {
 	return zl.lex != ""
 }
 
+func (zl zLex) excludes(member string) bool {
+	return false
+}
+
+// zLexExclusive is queried like an inclusive cap, with the member at the cap itself skipped in the results.
+type zLexExclusive struct {
+	lex string
+}
+
+func (zl zLexExclusive) ToAV() (av dynamodb.AttributeValue) {
+	av.S = aws.String(zl.lex)
+	return
+}
+
+func (zl zLexExclusive) present() bool {
+	return true
+}
+
+func (zl zLexExclusive) excludes(member string) bool {
+	return member == zl.lex
+}
+
+// ErrZInvalidBound is returned when a range bound is not a valid score, lexicographical bound or rank.
+var ErrZInvalidBound = errors.New("min or max is not a valid range bound")
+
+// ErrZSyntax is returned when range arguments are combined in a way that Redis doesn't allow.
+var ErrZSyntax = errors.New("syntax error")
+
+// ZBound is one end of a score or lexicographical range, which can be inclusive, exclusive or infinite.
+// Use ZScoreBound and ZExclusiveScoreBound for score ranges, ZLexBound and ZExclusiveLexBound for lexicographical
+// ranges, and ZNegInf and ZPosInf for the unbounded ends of either. ParseZScoreBound and ParseZLexBound accept
+// the Redis syntax. ZIntScoreBound and ZExclusiveIntScoreBound are exact for sets with integer scores added
+// with ZADDINT.
+type ZBound struct {
+	number    string
+	lex       string
+	exclusive bool
+	infinity  int
+}
+
+var (
+	ZNegInf = ZBound{infinity: -1}
+	ZPosInf = ZBound{infinity: +1}
+)
+
+func ZScoreBound(score float64) ZBound {
+	return zScoreBound(score, false)
+}
+
+func ZExclusiveScoreBound(score float64) ZBound {
+	return zScoreBound(score, true)
+}
+
+func zScoreBound(score float64, exclusive bool) ZBound {
+	switch {
+	case math.IsInf(score, -1):
+		return ZNegInf
+	case math.IsInf(score, +1):
+		return ZPosInf
+	}
+
+	return ZBound{number: zFormatScore(score), exclusive: exclusive}
+}
+
+func ZIntScoreBound(score int64) ZBound {
+	return ZBound{number: strconv.FormatInt(score, 10)}
+}
+
+func ZExclusiveIntScoreBound(score int64) ZBound {
+	return ZBound{number: strconv.FormatInt(score, 10), exclusive: true}
+}
+
+func ZLexBound(member string) ZBound {
+	return ZBound{lex: member}
+}
+
+func ZExclusiveLexBound(member string) ZBound {
+	return ZBound{lex: member, exclusive: true}
+}
+
+// ParseZScoreBound parses a score bound like 1.5, (1.5 (exclusive), -inf or +inf. Integers are parsed exactly.
+func ParseZScoreBound(bound string) (ZBound, error) {
+	exclusive := strings.HasPrefix(bound, "(")
+
+	if integer, err := strconv.ParseInt(strings.TrimPrefix(bound, "("), 10, 64); err == nil {
+		return ZBound{number: strconv.FormatInt(integer, 10), exclusive: exclusive}, nil
+	}
+
+	score, err := strconv.ParseFloat(strings.TrimPrefix(bound, "("), 64)
+	if err != nil || math.IsNaN(score) {
+		return ZBound{}, ErrZInvalidBound
+	}
+
+	return zScoreBound(score, exclusive), nil
+}
+
+// ParseZLexBound parses a lexicographical bound like [a (inclusive), (a (exclusive), - or +.
+func ParseZLexBound(bound string) (ZBound, error) {
+	switch {
+	case bound == "-":
+		return ZNegInf, nil
+	case bound == "+":
+		return ZPosInf, nil
+	case strings.HasPrefix(bound, "["):
+		return ZLexBound(bound[1:]), nil
+	case strings.HasPrefix(bound, "("):
+		return ZExclusiveLexBound(bound[1:]), nil
+	}
+
+	return ZBound{}, ErrZInvalidBound
+}
+
+// scoreNumber is the inclusive limit of a finite bound, with exclusive bounds moved to the adjacent number
+// inside the range.
+func (b ZBound) scoreNumber(lower bool) string {
+	if b.exclusive {
+		return zAdjacentNumber(b.number, lower)
+	}
+
+	return b.number
+}
+
+func (b ZBound) scoreCap(lower bool) rangeCap {
+	if b.infinity != 0 {
+		return zScore{math.Inf(b.infinity)}
+	}
+
+	return zNumber{b.scoreNumber(lower)}
+}
+
+// scoreLimit is the inclusive limit of the bound as a float64, for the rank index.
+func (b ZBound) scoreLimit(lower bool) float64 {
+	if b.infinity != 0 {
+		return math.Inf(b.infinity)
+	}
+
+	limit, _ := strconv.ParseFloat(b.scoreNumber(lower), 64)
+
+	return limit
+}
+
+func (b ZBound) lexCap() rangeCap {
+	switch {
+	case b.infinity != 0:
+		return zLex{}
+	case b.exclusive:
+		return zLexExclusive{b.lex}
+	}
+
+	return zLex{b.lex}
+}
+
+// zBoundsEmpty checks if no member can fall between the bounds, which DynamoDB would reject as an invalid BETWEEN.
+func zBoundsEmpty(min, max ZBound, lexical bool) bool {
+	switch {
+	case min.infinity > 0 || max.infinity < 0:
+		return true
+	case min.infinity < 0 || max.infinity > 0:
+		return lexical && max.infinity == 0 && max.lex == ""
+	case lexical:
+		return min.lex > max.lex || min.lex == max.lex && (min.exclusive || max.exclusive) || max.lex == ""
+	}
+
+	comparison := zCompareNumbers(min.number, max.number)
+
+	return comparison > 0 || comparison == 0 && (min.exclusive || max.exclusive)
+}
+
+func (c Client) zBoundedRange(key string, min, max ZBound, offset, count int64, forward, lexical bool) ([]ZMember, error) {
+	membersWithScores, _, err := c.zBoundedRangeRaw(key, min, max, offset, count, forward, lexical)
+	return membersWithScores, err
+}
+
+// zBoundedRangeRaw is zBoundedRange that also returns the scores exactly as they are stored.
+func (c Client) zBoundedRangeRaw(key string, min, max ZBound, offset, count int64,
+	forward, lexical bool) ([]ZMember, []string, error) {
+	if zBoundsEmpty(min, max, lexical) {
+		return nil, nil, nil
+	}
+
+	if lexical {
+		return c.zGeneralRangeRaw(key, min.lexCap(), max.lexCap(), offset, count, forward, c.sk)
+	}
+
+	return c.zGeneralRangeRaw(key, min.scoreCap(true), max.scoreCap(false), offset, count, forward, c.skN)
+}
+
 func zScoreFromAV(av dynamodb.AttributeValue) float64 {
 	f, _ := strconv.ParseFloat(aws.StringValue(av.N), 64)
 	return f
 }
 
-func (c Client) ZADD(key string, membersWithScores map[string]float64, flags Flags) (addedMembers []string, err error) {
-	for member, score := range membersWithScores {
-		builder := newExpresionBuilder()
-		builder.updateSetAV(c.skN, zScore{score}.ToAV())
+// ZADD sets the score of each of the given members, adding the members that are not already in the set.
+// The returned slice contains the members that were added, or, if the ReturnChanged flag is given, the members
+// that were added or whose score was changed.
+//
+// The update of each member can be made conditional with flags: IfNotExists only adds new members,
+// IfAlreadyExists only updates existing members, and IfGreaterThan / IfLessThan only update existing members
+// if the new score is greater / less than the current score.
+// IfGreaterThan and IfLessThan do not prevent new members from being added. IfNotExists cannot be
+// combined with IfGreaterThan or IfLessThan, and IfGreaterThan cannot be combined with IfLessThan – doing
+// so returns ErrZIncompatibleFlags.
+//
+// Scores must be numbers that DynamoDB can store: NaN returns ErrZInvalidScore, and infinities and magnitudes
+// beyond 1E+126 or below 1E-130 return ErrZScoreOutOfRange. Nothing is written if any score is invalid.
+//
+// Each member is written with a single conditional update, so cost is O(N) / N WCUs for N members.
+//
+// Works similar to https://redis.io/commands/zadd
+func (c Client) ZADD(key string, membersWithScores map[string]float64, flags Flags) (members []string, err error) {
+	numbers := make(map[string]string, len(membersWithScores))
 
-		if flags.has(IfNotExists) {
-			builder.addConditionNotExists(c.pk)
+	for member, score := range membersWithScores {
+		if err = zValidateScore(score); err != nil {
+			return
 		}
 
-		if flags.has(IfAlreadyExists) {
-			builder.addConditionExists(c.pk)
-		}
+		numbers[member] = zFormatScore(score)
+	}
+
+	return c.zAdd(key, numbers, flags)
+}
+
+func (c Client) zAdd(key string, membersWithScores map[string]string, flags Flags) (members []string, err error) {
+	if err = zCheckFlags(flags); err != nil {
+		return
+	}
+
+	if c.zRankIndexEnabled() {
+		return c.zIndexedADD(key, membersWithScores, flags)
+	}
+
+	for member, score := range membersWithScores {
+		builder := newExpresionBuilder()
+		builder.updateSetAV(c.skN, zNumber{score}.ToAV())
+		c.zAddConditions(&builder, flags, fmt.Sprintf(":%v", c.skN))
 
 		resp, err := c.ddbClient.UpdateItemRequest(&dynamodb.UpdateItemInput{
 			ConditionExpression:       builder.conditionExpression(),
@@ -105,11 +340,176 @@ func (c Client) ZADD(key string, membersWithScores map[string]float64, flags Fla
 		}
 
 		if err != nil {
-			return addedMembers, err
+			return members, err
+		}
+
+		oldScoreAV, existed := resp.Attributes[c.skN]
+		if !existed || flags.has(ReturnChanged) && zCompareNumbers(aws.StringValue(oldScoreAV.N), score) != 0 {
+			members = append(members, member)
+		}
+
+		if !existed {
+			if err = c.adjustCardinality(key, 1); err != nil {
+				return members, err
+			}
+		}
+	}
+
+	return
+}
+
+// ZADDINCR increments the score of the member by delta, like ZINCRBY, but accepts the same condition
+// flags as ZADD. The new score is returned, with ok set to false if the conditions prevented the update.
+//
+// Cost is O(1) / 1 WCU.
+//
+// Works similar to https://redis.io/commands/zadd with the INCR option
+func (c Client) ZADDINCR(key string, member string, delta float64, flags Flags) (newScore float64, ok bool, err error) {
+	if err = zValidateScore(delta); err != nil {
+		return
+	}
+
+	if err = zCheckFlags(flags); err != nil {
+		return
+	}
+
+	if c.zRankIndexEnabled() {
+		_, newScore, ok, err = c.zIndexedUpdate(key, member, func(oldScore string, exists bool) (string, bool) {
+			if !exists {
+				oldScore = "0"
+			}
+
+			score := zAddNumbers(oldScore, zFormatScore(delta))
+
+			return score, zAddPermitted(flags, exists, oldScore, score)
+		})
+
+		return
+	}
+
+	builder := newExpresionBuilder()
+	builder.clauses["ADD"] = append(builder.clauses["ADD"], fmt.Sprintf("#%v :delta", c.skN))
+	builder.keys[c.skN] = struct{}{}
+	builder.values["delta"] = zScore{delta}.ToAV()
+
+	// The new score is greater than the current one exactly when delta is positive, so IfGreaterThan
+	// and IfLessThan reduce to allowing or disallowing the update of existing members.
+	incrFlags := Flags{}
+
+	for _, flag := range flags {
+		switch {
+		case flag == IfGreaterThan && !(delta > 0), flag == IfLessThan && !(delta < 0):
+			incrFlags = append(incrFlags, IfNotExists)
+		case flag != IfGreaterThan && flag != IfLessThan:
+			incrFlags = append(incrFlags, flag)
+		}
+	}
+
+	c.zAddConditions(&builder, incrFlags, "")
+
+	counting := c.countsCardinality(key)
+	returnValues := dynamodb.ReturnValueAllNew
+
+	if counting {
+		returnValues = dynamodb.ReturnValueAllOld
+	}
+
+	resp, err := c.ddbClient.UpdateItemRequest(&dynamodb.UpdateItemInput{
+		ConditionExpression:       builder.conditionExpression(),
+		ExpressionAttributeNames:  builder.expressionAttributeNames(),
+		ExpressionAttributeValues: builder.expressionAttributeValues(),
+		Key:                       keyDef{pk: key, sk: member}.toAV(c),
+		ReturnValues:              returnValues,
+		TableName:                 aws.String(c.table),
+		UpdateExpression:          builder.updateExpression(),
+	}).Send(context.TODO())
+	if conditionFailureError(err) {
+		return newScore, false, nil
+	}
+
+	if err != nil {
+		return newScore, false, err
+	}
+
+	if !counting {
+		return zScoreFromAV(resp.Attributes[c.skN]), true, nil
+	}
+
+	oldScore := "0"
+	if oldScoreAV, existed := resp.Attributes[c.skN]; existed {
+		oldScore = aws.StringValue(oldScoreAV.N)
+	}
+
+	newScore = zScoreFromAV(zNumber{zAddNumbers(oldScore, zFormatScore(delta))}.ToAV())
+
+	if len(resp.Attributes) == 0 {
+		err = c.adjustCardinality(key, 1)
+	}
+
+	return newScore, true, err
+}
+
+// ErrZIncompatibleFlags is returned by ZADD and ZADDINCR when IfNotExists is combined with IfGreaterThan or
+// IfLessThan, or IfGreaterThan is combined with IfLessThan.
+var ErrZIncompatibleFlags = errors.New("GT, LT, and NX options at the same time are not compatible")
+
+func zCheckFlags(flags Flags) error {
+	if flags.has(IfGreaterThan) && flags.has(IfLessThan) ||
+		flags.has(IfNotExists) && (flags.has(IfGreaterThan) || flags.has(IfLessThan)) {
+		return ErrZIncompatibleFlags
+	}
+
+	return nil
+}
+
+// zAddConditions adds the conditions for the ZADD flags to the builder. The scoreRef is the expression
+// placeholder for the new score, which is only needed for IfGreaterThan and IfLessThan.
+func (c Client) zAddConditions(builder *expressionBuilder, flags Flags, scoreRef string) {
+	if flags.has(IfNotExists) {
+		builder.addConditionNotExists(c.pk)
+	}
+
+	if flags.has(IfAlreadyExists) {
+		builder.addConditionExists(c.pk)
+	}
+
+	if flags.has(IfGreaterThan) {
+		builder.condition(fmt.Sprintf("(attribute_not_exists(#%v) OR #%v < %v)", c.pk, c.skN, scoreRef), c.pk, c.skN)
+	}
+
+	if flags.has(IfLessThan) {
+		builder.condition(fmt.Sprintf("(attribute_not_exists(#%v) OR #%v > %v)", c.pk, c.skN, scoreRef), c.pk, c.skN)
+	}
+}
+
+// zAddPermitted evaluates the ZADD flags client side, for the rank indexed path that reads the current score first.
+func zAddPermitted(flags Flags, exists bool, oldScore, newScore string) bool {
+	switch {
+	case exists && flags.has(IfNotExists), !exists && flags.has(IfAlreadyExists):
+		return false
+	case exists && flags.has(IfGreaterThan):
+		return zCompareNumbers(newScore, oldScore) > 0
+	case exists && flags.has(IfLessThan):
+		return zCompareNumbers(newScore, oldScore) < 0
+	}
+
+	return true
+}
+
+func (c Client) zIndexedADD(key string, membersWithScores map[string]string, flags Flags) (members []string, err error) {
+	for member, score := range membersWithScores {
+		var previousScore string
+
+		existed, _, written, err := c.zIndexedUpdate(key, member, func(oldScore string, exists bool) (string, bool) {
+			previousScore = oldScore
+			return score, zAddPermitted(flags, exists, oldScore, score)
+		})
+		if err != nil {
+			return members, err
 		}
 
-		if len(resp.Attributes) == 0 {
-			addedMembers = append(addedMembers, member)
+		if written && (!existed || flags.has(ReturnChanged) && zCompareNumbers(previousScore, score) != 0) {
+			members = append(members, member)
 		}
 	}
 
@@ -120,8 +520,19 @@ func (c Client) ZCARD(key string) (count int64, err error) {
 	return c.HLEN(key)
 }
 
-func (c Client) ZCOUNT(key string, minScore, maxScore float64) (count int64, err error) {
-	return c.zGeneralCount(key, zScore{minScore}, zScore{maxScore}, c.skN)
+// ZCOUNT counts the members with scores between min and max.
+//
+// Works similar to https://redis.io/commands/zcount
+func (c Client) ZCOUNT(key string, min, max ZBound) (count int64, err error) {
+	if zBoundsEmpty(min, max, false) {
+		return 0, nil
+	}
+
+	if c.zRankIndexEnabled() {
+		return c.zIndexedCount(key, min.scoreLimit(true), max.scoreLimit(false))
+	}
+
+	return c.zGeneralCount(key, min.scoreCap(true), max.scoreCap(false), c.skN)
 }
 
 func (c Client) zGeneralCount(key string, min rangeCap, max rangeCap, attribute string) (count int64, err error) {
@@ -188,130 +599,505 @@ func (c Client) zGeneralCount(key string, min rangeCap, max rangeCap, attribute
 	return
 }
 
+// ZDIFF returns the members of the first set that are not in any of the other sets, with their scores
+// from the first set, ordered by score and then by member.
+//
+// Cost is O(N) / N RCUs for N members in the first set, and at most N RCUs for every other set.
+//
+// Works similar to https://redis.io/commands/zdiff
+func (c Client) ZDIFF(sourceKeys []string) (membersWithScores []ZMember, err error) {
+	membersWithScores, _, err = c.zDiff(sourceKeys)
+	return
+}
+
+// zDiff is ZDIFF along with the scores exactly as they are stored.
+func (c Client) zDiff(sourceKeys []string) (membersWithScores []ZMember, rawScores []string, err error) {
+	if len(sourceKeys) == 0 {
+		return
+	}
+
+	membersWithScores, rawScores, err = c.zGeneralRangeRaw(sourceKeys[0], negInf, posInf, 0, 0, true, c.skN)
+	if err != nil {
+		return
+	}
+
+	for _, sourceKey := range sourceKeys[1:] {
+		if len(membersWithScores) == 0 {
+			break
+		}
+
+		remainingMembers := make([]string, len(membersWithScores))
+		for i, zm := range membersWithScores {
+			remainingMembers[i] = zm.Member
+		}
+
+		commonMembers, err := c.ZMSCORE(sourceKey, remainingMembers...)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		kept := 0
+
+		for i, zm := range membersWithScores {
+			if _, ok := commonMembers[zm.Member]; !ok {
+				membersWithScores[kept], rawScores[kept] = zm, rawScores[i]
+				kept++
+			}
+		}
+
+		membersWithScores, rawScores = membersWithScores[:kept], rawScores[:kept]
+	}
+
+	return
+}
+
+// ZDIFFSTORE stores the result of ZDIFF at the destination key, replacing any members already there. Scores are
+// copied exactly as they are stored, and the destination is replaced as in ZRANGESTORE.
+//
+// Works similar to https://redis.io/commands/zdiffstore
+func (c Client) ZDIFFSTORE(destinationKey string, sourceKeys []string) (count int64, err error) {
+	membersWithScores, rawScores, err := c.zDiff(sourceKeys)
+	if err != nil {
+		return
+	}
+
+	scores := make(map[string]string, len(membersWithScores))
+	for i, zm := range membersWithScores {
+		scores[zm.Member] = rawScores[i]
+	}
+
+	return c.zReplace(destinationKey, scores)
+}
+
 func (c Client) ZINCRBY(key string, member string, delta float64) (newScore float64, err error) {
-	builder := newExpresionBuilder()
-	builder.keys[c.skN] = struct{}{}
-	builder.values["delta"] = zScore{delta}.ToAV()
+	newScore, _, err = c.ZADDINCR(key, member, delta, Flags{})
+	return
+}
 
-	resp, err := c.ddbClient.UpdateItemRequest(&dynamodb.UpdateItemInput{
-		ConditionExpression:       builder.conditionExpression(),
-		ExpressionAttributeNames:  builder.expressionAttributeNames(),
-		ExpressionAttributeValues: builder.expressionAttributeValues(),
-		Key: keyDef{
-			pk: key,
-			sk: member,
-		}.toAV(c),
-		ReturnValues:     dynamodb.ReturnValueAllNew,
-		TableName:        aws.String(c.table),
-		UpdateExpression: aws.String(fmt.Sprintf("ADD #%v :delta", c.skN)),
-	}).Send(context.TODO())
+// ZINTERSTORE stores the intersection of the source sets at the destination key, replacing any members already
+// there, and returns the number of members stored. The scores are multiplied by the weight of their source
+// (1 if not given) and then aggregated.
+//
+// The sources are read page by page and aggregated in a scratch set, so they don't have to fit in memory.
+// Cost is O(N) / N RCUs and WCUs for the N members of all sources, plus the writes to the destination. The
+// destination is replaced atomically if no more than 100 of its members change; larger changes are made in
+// several transactions, adding and updating members before removing stale ones.
+//
+// Works similar to https://redis.io/commands/zinterstore
+func (c Client) ZINTERSTORE(destinationKey string, sourceKeys []string, aggregation ZAggregation, weights map[string]float64) (count int64, err error) {
+	return c.zStore(destinationKey, sourceKeys, aggregation, weights, true)
+}
+
+// ZLEXCOUNT counts the members between min and max lexicographically.
+//
+// Works similar to https://redis.io/commands/zlexcount
+func (c Client) ZLEXCOUNT(key string, min, max ZBound) (count int64, err error) {
+	if zBoundsEmpty(min, max, true) {
+		return 0, nil
+	}
+
+	count, err = c.zGeneralCount(key, min.lexCap(), max.lexCap(), c.sk)
 	if err != nil {
-		return newScore, err
+		return
 	}
 
-	newScore = zScoreFromAV(resp.Attributes[c.skN])
+	// The count query is inclusive, so exclusive bounds that are members were counted.
+	for _, bound := range []ZBound{min, max} {
+		if !bound.exclusive || bound.infinity != 0 {
+			continue
+		}
+
+		_, found, err := c.ZSCORE(key, bound.lex)
+		if err != nil {
+			return count, err
+		}
+
+		if found && count > 0 {
+			count--
+		}
+	}
 
 	return
 }
 
-func (c Client) ZINTERSTORE(destinationKey string, sourceKeys []string, aggregation ZAggregation, weights map[string]float64) (membersWithScores map[string]float64, err error) {
-	set, err := c.ZINTER(sourceKeys, aggregation, weights)
-	if err == nil {
-		_, err = c.ZADD(destinationKey, set, Flags{})
+type ZSide string
+
+const (
+	ZMin ZSide = "MIN"
+	ZMax ZSide = "MAX"
+)
+
+// ZMPOP pops up to count members with the lowest (ZMin) or highest (ZMax) scores from the first of the given keys that
+// has any members, returning that key along with the popped members. If all the sets are empty, the key is empty.
+//
+// Works similar to https://redis.io/commands/zmpop
+func (c Client) ZMPOP(keys []string, side ZSide, count int64) (key string, membersWithScores []ZMember, err error) {
+	for _, sourceKey := range keys {
+		membersWithScores, err = c.zPop(sourceKey, count, side == ZMin)
+		if err != nil || len(membersWithScores) > 0 {
+			return sourceKey, membersWithScores, err
+		}
 	}
 
-	return set, err
+	return
 }
 
-func (c Client) ZLEXCOUNT(key string, min string, max string) (count int64, err error) {
-	return c.zGeneralCount(key, zLex{min}, zLex{max}, c.sk)
+// ZMSCORE returns the scores of the given members. Members that are not in the set are missing from the result.
+//
+// Cost is O(N) / N RCUs for N members, read with BatchGetItem in batches of 100.
+//
+// Works similar to https://redis.io/commands/zmscore
+func (c Client) ZMSCORE(key string, members ...string) (membersWithScores map[string]float64, err error) {
+	membersWithScores = make(map[string]float64)
+	keys := make([]keyDef, len(members))
+
+	for i, member := range members {
+		keys[i] = keyDef{pk: key, sk: member}
+	}
+
+	items, err := c.batchGet(keys)
+	for _, item := range items {
+		membersWithScores[parseKey(item, c).sk] = zScoreFromAV(item[c.skN])
+	}
+
+	return
 }
 
-func (c Client) ZPOPMAX(key string, count int64) (membersWithScores map[string]float64, err error) {
+func (c Client) ZPOPMAX(key string, count int64) (membersWithScores []ZMember, err error) {
 	return c.zPop(key, count, false)
 }
 
-func (c Client) ZPOPMIN(key string, count int64) (membersWithScores map[string]float64, err error) {
+func (c Client) ZPOPMIN(key string, count int64) (membersWithScores []ZMember, err error) {
 	return c.zPop(key, count, true)
 }
 
 var negInf = zScore{math.Inf(-1)}
 var posInf = zScore{math.Inf(+1)}
 
-func (c Client) zPop(key string, count int64, forward bool) (membersWithScores map[string]float64, err error) {
-	membersWithScores, err = c.zGeneralRange(key, negInf, posInf, 0, count, forward, c.skN)
+// zPop removes the members with the lowest (or highest) scores. Each member is deleted on the condition that it
+// still has the score it was read with, so that concurrent pops never return the same member twice – a member
+// taken by someone else (or rescored) is replaced by reading the next candidates, until count members are
+// popped or the set is empty.
+func (c Client) zPop(key string, count int64, forward bool) (membersWithScores []ZMember, err error) {
+	for int64(len(membersWithScores)) < count {
+		candidates, rawScores, err := c.zGeneralRangeRaw(key, negInf, posInf, 0, count-int64(len(membersWithScores)), forward, c.skN)
+		if err != nil || len(candidates) == 0 {
+			return membersWithScores, err
+		}
+
+		for i, zm := range candidates {
+			popped, err := c.zPopMember(key, zm.Member, rawScores[i])
+			if err != nil {
+				return membersWithScores, err
+			}
+
+			if popped {
+				membersWithScores = append(membersWithScores, zm)
+			}
+		}
+	}
+
+	return
+}
+
+func (c Client) zPopMember(key string, member string, rawScore string) (popped bool, err error) {
+	if c.zRankIndexEnabled() {
+		return c.zIndexedRemoveIf(key, member, func(score string) bool { return score == rawScore })
+	}
+
+	builder := newExpresionBuilder()
+	builder.addConditionEquality(c.skN, zNumber{rawScore})
+
+	_, err = c.ddbClient.DeleteItemRequest(&dynamodb.DeleteItemInput{
+		ConditionExpression:       builder.conditionExpression(),
+		ExpressionAttributeNames:  builder.expressionAttributeNames(),
+		ExpressionAttributeValues: builder.expressionAttributeValues(),
+		Key:                       keyDef{pk: key, sk: member}.toAV(c),
+		TableName:                 aws.String(c.table),
+	}).Send(context.TODO())
+	if conditionFailureError(err) {
+		return false, nil
+	}
+
 	if err != nil {
-		return
+		return false, err
 	}
 
-	poppedMembers := make(map[string]float64)
+	return true, c.adjustCardinality(key, -1)
+}
 
-	for member, score := range membersWithScores {
-		popped, err := c.ZREM(key, member)
-		if err != nil {
-			return poppedMembers, err
-		}
+// ZRANDMEMBER returns up to count distinct random members with their scores. If count is negative, exactly -count
+// members are returned, and the same member may be returned more than once.
+//
+// Every member of the set is read to pick from, so cost is O(N) / N RCUs.
+//
+// Works similar to https://redis.io/commands/zrandmember
+func (c Client) ZRANDMEMBER(key string, count int64) (membersWithScores []ZMember, err error) {
+	allMembers, err := c.zGeneralRange(key, negInf, posInf, 0, 0, true, c.skN)
+	if err != nil || len(allMembers) == 0 {
+		return nil, err
+	}
 
-		if len(popped) > 0 {
-			poppedMembers[member] = score
+	if count < 0 {
+		for i := int64(0); i < -count; i++ {
+			membersWithScores = append(membersWithScores, allMembers[rand.Intn(len(allMembers))])
 		}
+
+		return
 	}
 
-	return poppedMembers, err
+	rand.Shuffle(len(allMembers), func(i, j int) {
+		allMembers[i], allMembers[j] = allMembers[j], allMembers[i]
+	})
+
+	if count < int64(len(allMembers)) {
+		allMembers = allMembers[:count]
+	}
+
+	return allMembers, nil
 }
 
-func (c Client) ZRANGE(key string, start, stop int64) (membersWithScores map[string]float64, err error) {
+func (c Client) ZRANGE(key string, start, stop int64) (membersWithScores []ZMember, err error) {
 	return c.zRange(key, start, stop, true)
 }
 
-func (c Client) zRange(key string, start int64, stop int64, forward bool) (membersWithScores map[string]float64, err error) {
+// zRange returns the members ranked from start to stop, both inclusive. As in Redis, negative ranks count from the
+// end of the set, ranks beyond either end are clamped, and no members are returned if start comes after stop.
+func (c Client) zRange(key string, start int64, stop int64, forward bool) (membersWithScores []ZMember, err error) {
+	membersWithScores, _, err = c.zRangeRaw(key, start, stop, forward)
+	return
+}
+
+// zRangeRaw is zRange that also returns the scores exactly as they are stored.
+func (c Client) zRangeRaw(key string, start int64, stop int64,
+	forward bool) (membersWithScores []ZMember, rawScores []string, err error) {
 	if start < 0 && stop < 0 {
-		return c.zGeneralRange(key, negInf, posInf, -stop-1, -start, !forward, c.skN)
+		// Both ends are counted from the end, so read from the end without needing the cardinality.
+		if start > stop {
+			return nil, nil, nil
+		}
+
+		membersWithScores, rawScores, err = c.zGeneralRangeRaw(key, negInf, posInf, -stop-1, stop-start+1, !forward, c.skN)
+		zReverse(membersWithScores, rawScores)
+
+		return
 	}
 
-	if start > 0 && stop < 0 {
-		lastScore, err := c.zGeneralRange(key, negInf, posInf, -stop-1, 1, !forward, c.skN)
+	if start < 0 || stop < 0 {
+		cardinality, err := c.ZCARD(key)
 		if err != nil {
-			return membersWithScores, err
+			return nil, nil, err
+		}
+
+		if start < 0 {
+			start += cardinality
+		}
+
+		if start < 0 {
+			start = 0
+		}
+
+		if stop < 0 {
+			stop += cardinality
 		}
+	}
 
-		return c.zGeneralRange(key, negInf, zScore{floatValues(lastScore)[0]}, start, 0, forward, c.skN)
+	if start > stop {
+		return nil, nil, nil
 	}
 
-	return c.zGeneralRange(key, negInf, posInf, start, stop-start+1, forward, c.skN)
+	return c.zGeneralRangeRaw(key, negInf, posInf, start, stop-start+1, forward, c.skN)
 }
 
-func floatValues(floatValuedMap map[string]float64) (values []float64) {
-	for _, v := range floatValuedMap {
-		values = append(values, v)
-	}
+type ZRangeBy string
+
+const (
+	ZRangeByRank  ZRangeBy = ""
+	ZRangeByScore ZRangeBy = "BYSCORE"
+	ZRangeByLex   ZRangeBy = "BYLEX"
+)
+
+// ZRangeArgs are the arguments of ZRANGEARGS and ZRANGESTORE. Start and Stop use the Redis syntax: ranks like 0 and -1
+// by default, scores like 1.5, (1.5, -inf and +inf with ZRangeByScore, and members like [a, (a, - and + with
+// ZRangeByLex.
+//
+// Negative ranks count from the end of the set, so -1 is the last member. Ranks beyond either end are clamped, and
+// a Start that comes after Stop returns no members.
+//
+// Rev reverses the order of the results. For score and lexicographical ranges Start is then the upper end of the
+// range and Stop the lower end, as in Redis. Offset and Count limit score and lexicographical ranges only, with a
+// Count of zero or less returning all the remaining members and a negative Offset returning none.
+type ZRangeArgs struct {
+	Start  string
+	Stop   string
+	By     ZRangeBy
+	Rev    bool
+	Offset int64
+	Count  int64
+}
 
+// ZRANGEARGS is the unified range command, covering ZRANGE, ZREVRANGE, ZRANGEBYSCORE, ZREVRANGEBYSCORE, ZRANGEBYLEX
+// and ZREVRANGEBYLEX, with exclusive and infinite bounds. Invalid bounds return ErrZInvalidBound, and an Offset or
+// Count on a rank range returns ErrZSyntax.
+//
+// Cost is O(log(N) + M) / M RCUs for M members returned.
+//
+// Works similar to https://redis.io/commands/zrange
+func (c Client) ZRANGEARGS(key string, args ZRangeArgs) (membersWithScores []ZMember, err error) {
+	membersWithScores, _, err = c.zRangeArgs(key, args)
 	return
 }
 
-func (c Client) ZRANGEBYLEX(key string, min, max string, offset, count int64) (membersWithScores map[string]float64, err error) {
-	return c.zGeneralRange(key, zLex{min}, zLex{max}, offset, count, true, c.sk)
+// zRangeArgs is ZRANGEARGS that also returns the scores exactly as they are stored.
+func (c Client) zRangeArgs(key string, args ZRangeArgs) (membersWithScores []ZMember, rawScores []string, err error) {
+	switch args.By {
+	case ZRangeByRank:
+		if args.Offset != 0 || args.Count != 0 {
+			return nil, nil, ErrZSyntax
+		}
+
+		start, startErr := strconv.ParseInt(args.Start, 10, 64)
+		stop, stopErr := strconv.ParseInt(args.Stop, 10, 64)
+
+		if startErr != nil || stopErr != nil {
+			return nil, nil, ErrZInvalidBound
+		}
+
+		return c.zRangeRaw(key, start, stop, !args.Rev)
+	case ZRangeByScore, ZRangeByLex:
+		lexical := args.By == ZRangeByLex
+
+		parse := ParseZScoreBound
+		if lexical {
+			parse = ParseZLexBound
+		}
+
+		start, err := parse(args.Start)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		stop, err := parse(args.Stop)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if args.Rev {
+			start, stop = stop, start
+		}
+
+		return c.zBoundedRangeRaw(key, start, stop, args.Offset, args.Count, !args.Rev, lexical)
+	}
+
+	return nil, nil, ErrZSyntax
 }
 
-func (c Client) ZRANGEBYSCORE(key string, min, max float64, offset, count int64) (membersWithScores map[string]float64, err error) {
-	return c.zGeneralRange(key, zScore{min}, zScore{max}, offset, count, true, c.skN)
+// ZRANGESTORE stores the result of ZRANGEARGS on the source key at the destination key, replacing any members
+// already there, and returns the number of members stored. Scores are copied exactly as they are stored, so
+// integer scores set with ZADDINT keep their precision. The destination is replaced atomically if no more than
+// 100 of its members change; larger changes are made in several transactions, adding and updating members
+// before removing stale ones.
+//
+// Works similar to https://redis.io/commands/zrangestore
+func (c Client) ZRANGESTORE(destinationKey string, sourceKey string, args ZRangeArgs) (count int64, err error) {
+	membersWithScores, rawScores, err := c.zRangeArgs(sourceKey, args)
+	if err != nil {
+		return
+	}
+
+	scores := make(map[string]string, len(membersWithScores))
+	for i, zm := range membersWithScores {
+		scores[zm.Member] = rawScores[i]
+	}
+
+	return c.zReplace(destinationKey, scores)
 }
 
+// zReplace makes the given members the only members of the set at key, removing any others, with their scores
+// exactly as given.
+func (c Client) zReplace(key string, membersWithScores map[string]string) (count int64, err error) {
+	members := make([]string, 0, len(membersWithScores))
+	for member := range membersWithScores {
+		members = append(members, member)
+	}
+
+	sort.Strings(members)
+
+	return c.zReplaceWith(key, func() zSource {
+		next := 0
+
+		return func() (member string, score string, ok bool, err error) {
+			if next == len(members) {
+				return "", "", false, nil
+			}
+
+			member = members[next]
+			next++
+
+			return member, membersWithScores[member], true, nil
+		}
+	})
+}
+
+func zReverse(membersWithScores []ZMember, rawScores []string) {
+	for left, right := 0, len(membersWithScores)-1; left < right; left, right = left+1, right-1 {
+		membersWithScores[left], membersWithScores[right] = membersWithScores[right], membersWithScores[left]
+		rawScores[left], rawScores[right] = rawScores[right], rawScores[left]
+	}
+}
+
+func (c Client) ZRANGEBYLEX(key string, min, max ZBound, offset, count int64) (membersWithScores []ZMember, err error) {
+	return c.zBoundedRange(key, min, max, offset, count, true, true)
+}
+
+func (c Client) ZRANGEBYSCORE(key string, min, max ZBound, offset, count int64) (membersWithScores []ZMember, err error) {
+	return c.zBoundedRange(key, min, max, offset, count, true, false)
+}
+
+// zGeneralRange queries the members between start and stop on the given attribute – the member (sk) for
+// lexicographical ranges, or the score (skN) for score ranges – skipping offset members and returning at most
+// count members. A count of zero or less returns all the remaining members, and a negative offset returns
+// no members, as in Redis.
+//
+// Members with equal scores are ordered lexicographically, in reverse when the range is not forward. Because the
+// index doesn't guarantee an order among equal scores, a limited range is extended until the last score
+// fetched changes, so that ties at the end of the page are ordered correctly before the page is cut.
 func (c Client) zGeneralRange(key string,
 	start rangeCap, stop rangeCap,
 	offset int64, count int64,
-	forward bool, attribute string) (membersWithScores map[string]float64, err error) {
-	membersWithScores = make(map[string]float64)
-	index := int64(0)
-	remainingCount := count
+	forward bool, attribute string) (membersWithScores []ZMember, err error) {
+	membersWithScores, _, err = c.zGeneralRangeRaw(key, start, stop, offset, count, forward, attribute)
+	return
+}
+
+// zGeneralRangeRaw is zGeneralRange that also returns the scores exactly as they are stored.
+func (c Client) zGeneralRangeRaw(key string,
+	start rangeCap, stop rangeCap,
+	offset int64, count int64,
+	forward bool, attribute string) (membersWithScores []ZMember, rawScores []string, err error) {
+	if offset < 0 {
+		return nil, nil, nil
+	}
+
+	limit := int64(0)
+	if count > 0 {
+		limit = offset + count
+	}
+
 	hasMoreResults := true
 
 	var lastKey map[string]dynamodb.AttributeValue
 
 	for hasMoreResults {
 		var queryLimit *int64
-		if remainingCount > 0 {
-			queryLimit = aws.Int64(remainingCount + offset - index)
+
+		if limit > 0 {
+			remainingCount := limit - int64(len(membersWithScores))
+			if remainingCount < 1 {
+				remainingCount = 1
+			}
+
+			queryLimit = aws.Int64(remainingCount)
 		}
 
 		builder := newExpresionBuilder()
@@ -352,26 +1138,69 @@ func (c Client) zGeneralRange(key string,
 		}).Send(context.TODO())
 
 		if err != nil {
-			return membersWithScores, err
+			return membersWithScores, rawScores, err
 		}
 
+		hasMoreResults = len(resp.LastEvaluatedKey) > 0
+		lastKey = resp.LastEvaluatedKey
+
 		for _, item := range resp.Items {
-			if index >= offset {
-				pi := parseItem(item, c)
-				membersWithScores[pi.sk] = zScoreFromAV(item[c.skN])
-				remainingCount--
+			member := parseKey(item, c).sk
+			if start.excludes(member) || stop.excludes(member) {
+				continue
 			}
-			index++
-		}
 
-		if len(resp.LastEvaluatedKey) > 0 && remainingCount > 0 {
-			lastKey = resp.LastEvaluatedKey
-		} else {
-			hasMoreResults = false
+			rawScore := aws.StringValue(item[c.skN].N)
+
+			if limit > 0 && int64(len(membersWithScores)) >= limit &&
+				(attribute != c.skN || rawScore != rawScores[len(rawScores)-1]) {
+				hasMoreResults = false
+				break
+			}
+
+			membersWithScores = append(membersWithScores, ZMember{
+				Member: member,
+				Score:  zScoreFromAV(item[c.skN]),
+			})
+			rawScores = append(rawScores, rawScore)
 		}
 	}
 
-	return membersWithScores, nil
+	zSortTies(membersWithScores, rawScores, forward)
+
+	if offset >= int64(len(membersWithScores)) {
+		return nil, nil, nil
+	}
+
+	membersWithScores, rawScores = membersWithScores[offset:], rawScores[offset:]
+
+	if count > 0 && count < int64(len(membersWithScores)) {
+		membersWithScores, rawScores = membersWithScores[:count], rawScores[:count]
+	}
+
+	return membersWithScores, rawScores, nil
+}
+
+// zSortTies orders the runs of members with identical scores by member, leaving
+// the order between different scores – as returned by DynamoDB – untouched.
+func zSortTies(membersWithScores []ZMember, rawScores []string, forward bool) {
+	for runStart := 0; runStart < len(membersWithScores); {
+		runEnd := runStart + 1
+		for runEnd < len(membersWithScores) && rawScores[runEnd] == rawScores[runStart] {
+			runEnd++
+		}
+
+		run := membersWithScores[runStart:runEnd]
+		sort.Slice(run, func(i, j int) bool {
+			if forward {
+				return run[i].Member < run[j].Member
+			}
+
+			return run[i].Member > run[j].Member
+		})
+
+		runStart = runEnd
+	}
 }
 
 func (c Client) ZRANK(key string, member string) (rank int64, found bool, err error) {
@@ -379,7 +1208,11 @@ func (c Client) ZRANK(key string, member string) (rank int64, found bool, err er
 }
 
 func (c Client) zRank(key string, member string, forward bool) (rank int64, ok bool, err error) {
-	score, ok, err := c.ZSCORE(key, member)
+	if c.zRankIndexEnabled() {
+		return c.zIndexedRank(key, member, forward)
+	}
+
+	score, ok, err := c.zScoreNumber(key, member)
 	if err != nil || !ok {
 		return
 	}
@@ -387,13 +1220,67 @@ func (c Client) zRank(key string, member string, forward bool) (rank int64, ok b
 	var count int64
 
 	if forward {
-		count, err = c.zGeneralCount(key, negInf, zScore{score}, c.skN)
+		count, err = c.zGeneralCount(key, negInf, zNumber{score}, c.skN)
 	} else {
-		count, err = c.zGeneralCount(key, zScore{score}, posInf, c.skN)
+		count, err = c.zGeneralCount(key, zNumber{score}, posInf, c.skN)
 	}
 
+	if err != nil {
+		return
+	}
+
+	tiesAfter, err := c.zTiesAfter(key, member, score, forward)
 	if err == nil {
-		rank = count - 1
+		rank = count - 1 - tiesAfter
+	}
+
+	return
+}
+
+// zTiesAfter counts the members with the same score as the given member that rank after it – the ones that
+// sort after it, or before it in reverse order. Members with equal scores are ranked by the member itself.
+func (c Client) zTiesAfter(key string, member string, score string, forward bool) (count int64, err error) {
+	builder := newExpresionBuilder()
+	builder.addConditionEquality(c.pk, StringValue{key})
+	builder.addConditionEquality(c.skN, zNumber{score})
+
+	operator := ">"
+	if !forward {
+		operator = "<"
+	}
+
+	builder.keys[c.sk] = struct{}{}
+	builder.values["member"] = StringValue{member}.ToAV()
+	filter := aws.String(fmt.Sprintf("#%v %v :member", c.sk, operator))
+
+	hasMoreResults := true
+
+	var lastEvaluatedKey map[string]dynamodb.AttributeValue
+
+	for hasMoreResults {
+		resp, err := c.ddbClient.QueryRequest(&dynamodb.QueryInput{
+			ConsistentRead:            aws.Bool(c.consistentReads),
+			ExclusiveStartKey:         lastEvaluatedKey,
+			ExpressionAttributeNames:  builder.expressionAttributeNames(),
+			ExpressionAttributeValues: builder.expressionAttributeValues(),
+			FilterExpression:          filter,
+			IndexName:                 aws.String(c.index),
+			KeyConditionExpression:    builder.conditionExpression(),
+			Select:                    dynamodb.SelectCount,
+			TableName:                 aws.String(c.table),
+		}).Send(context.TODO())
+
+		if err != nil {
+			return count, err
+		}
+
+		count += aws.Int64Value(resp.Count)
+
+		if len(resp.LastEvaluatedKey) > 0 {
+			lastEvaluatedKey = resp.LastEvaluatedKey
+		} else {
+			hasMoreResults = false
+		}
 	}
 
 	return
@@ -401,6 +1288,19 @@ func (c Client) zRank(key string, member string, forward bool) (rank int64, ok b
 
 func (c Client) ZREM(key string, members ...string) (removedMembers []string, err error) {
 	for _, member := range members {
+		if c.zRankIndexEnabled() {
+			removed, err := c.zIndexedRemove(key, member)
+			if err != nil {
+				return removedMembers, err
+			}
+
+			if removed {
+				removedMembers = append(removedMembers, member)
+			}
+
+			continue
+		}
+
 		resp, err := c.ddbClient.DeleteItemRequest(&dynamodb.DeleteItemInput{
 			Key:          keyDef{pk: key, sk: member}.toAV(c),
 			ReturnValues: dynamodb.ReturnValueAllOld,
@@ -413,13 +1313,17 @@ func (c Client) ZREM(key string, members ...string) (removedMembers []string, er
 
 		if len(resp.Attributes) > 0 {
 			removedMembers = append(removedMembers, member)
+
+			if err = c.adjustCardinality(key, -1); err != nil {
+				return removedMembers, err
+			}
 		}
 	}
 
 	return
 }
 
-func (c Client) ZREMRANGEBYLEX(key string, min, max string) (removedMembers []string, err error) {
+func (c Client) ZREMRANGEBYLEX(key string, min, max ZBound) (removedMembers []string, err error) {
 	membersWithScores, err := c.ZRANGEBYLEX(key, min, max, 0, 0)
 	if err == nil {
 		removedMembers, err = c.ZREM(key, zReadKeys(membersWithScores)...)
@@ -428,15 +1332,24 @@ func (c Client) ZREMRANGEBYLEX(key string, min, max string) (removedMembers []st
 	return
 }
 
-func zReadKeys(membersWithScores map[string]float64) []string {
+func zReadKeys(membersWithScores []ZMember) []string {
 	members := make([]string, 0, len(membersWithScores))
-	for member := range membersWithScores {
-		members = append(members, member)
+	for _, zm := range membersWithScores {
+		members = append(members, zm.Member)
 	}
 
 	return members
 }
 
+func zToMap(membersWithScores []ZMember) map[string]float64 {
+	m := make(map[string]float64, len(membersWithScores))
+	for _, zm := range membersWithScores {
+		m[zm.Member] = zm.Score
+	}
+
+	return m
+}
+
 func (c Client) ZREMRANGEBYRANK(key string, start, stop int64) (removedMembers []string, err error) {
 	membersWithScores, err := c.ZRANGE(key, start, stop)
 	if err == nil {
@@ -446,7 +1359,7 @@ func (c Client) ZREMRANGEBYRANK(key string, start, stop int64) (removedMembers [
 	return
 }
 
-func (c Client) ZREMRANGEBYSCORE(key string, min, max float64) (removedMembers []string, err error) {
+func (c Client) ZREMRANGEBYSCORE(key string, min, max ZBound) (removedMembers []string, err error) {
 	membersWithScores, err := c.ZRANGEBYSCORE(key, min, max, 0, 0)
 	if err == nil {
 		removedMembers, err = c.ZREM(key, zReadKeys(membersWithScores)...)
@@ -455,16 +1368,16 @@ func (c Client) ZREMRANGEBYSCORE(key string, min, max float64) (removedMembers [
 	return
 }
 
-func (c Client) ZREVRANGE(key string, start, stop int64) (membersWithScores map[string]float64, err error) {
+func (c Client) ZREVRANGE(key string, start, stop int64) (membersWithScores []ZMember, err error) {
 	return c.zRange(key, start, stop, false)
 }
 
-func (c Client) ZREVRANGEBYLEX(key string, max, min string, offset, count int64) (membersWithScores map[string]float64, err error) {
-	return c.zGeneralRange(key, zLex{min}, zLex{max}, offset, count, false, c.sk)
+func (c Client) ZREVRANGEBYLEX(key string, max, min ZBound, offset, count int64) (membersWithScores []ZMember, err error) {
+	return c.zBoundedRange(key, min, max, offset, count, false, true)
 }
 
-func (c Client) ZREVRANGEBYSCORE(key string, max, min float64, offset, count int64) (membersWithScores map[string]float64, err error) {
-	return c.zGeneralRange(key, zScore{min}, zScore{max}, offset, count, false, c.skN)
+func (c Client) ZREVRANGEBYSCORE(key string, max, min ZBound, offset, count int64) (membersWithScores []ZMember, err error) {
+	return c.zBoundedRange(key, min, max, offset, count, false, false)
 }
 
 func (c Client) ZREVRANK(key string, member string) (rank int64, found bool, err error) {
@@ -472,30 +1385,26 @@ func (c Client) ZREVRANK(key string, member string) (rank int64, found bool, err
 }
 
 func (c Client) ZSCORE(key string, member string) (score float64, found bool, err error) {
-	resp, err := c.ddbClient.GetItemRequest(&dynamodb.GetItemInput{
-		ConsistentRead: aws.Bool(c.consistentReads),
-		Key: keyDef{
-			pk: key,
-			sk: member,
-		}.toAV(c),
-		ProjectionExpression: aws.String(strings.Join([]string{c.skN}, ", ")),
-		TableName:            aws.String(c.table),
-	}).Send(context.TODO())
-	if err == nil && len(resp.Item) > 0 {
-		found = true
-		score = zScoreFromAV(resp.Item[c.skN])
+	number, found, err := c.zScoreNumber(key, member)
+	if found {
+		score = zScoreFromAV(zNumber{number}.ToAV())
 	}
 
 	return
 }
 
-func (c Client) ZUNIONSTORE(destinationKey string, sourceKeys []string, aggregation ZAggregation, weights map[string]float64) (membersWithScores map[string]float64, err error) {
-	set, err := c.ZUNION(sourceKeys, aggregation, weights)
-	if err == nil {
-		_, err = c.ZADD(destinationKey, set, Flags{})
-	}
-
-	return set, err
+// ZUNIONSTORE stores the union of the source sets at the destination key, replacing any members already there,
+// and returns the number of members stored. The scores are multiplied by the weight of their source (1 if not
+// given) and then aggregated.
+//
+// The sources are read page by page and aggregated in a scratch set, so they don't have to fit in memory.
+// Cost is O(N) / N RCUs and WCUs for the N members of all sources, plus the writes to the destination. The
+// destination is replaced atomically if no more than 100 of its members change; larger changes are made in
+// several transactions, adding and updating members before removing stale ones.
+//
+// Works similar to https://redis.io/commands/zunionstore
+func (c Client) ZUNIONSTORE(destinationKey string, sourceKeys []string, aggregation ZAggregation, weights map[string]float64) (count int64, err error) {
+	return c.zStore(destinationKey, sourceKeys, aggregation, weights, false)
 }
 
 func zGetWeight(weights map[string]float64, key string) float64 {
@@ -513,12 +1422,12 @@ func (c Client) ZUNION(sourceKeys []string, aggregation ZAggregation, weights ma
 	membersWithScores = make(map[string]float64)
 
 	for _, sourceKey := range sourceKeys {
-		currentSet, err := c.ZRANGEBYSCORE(sourceKey, math.Inf(-1), math.Inf(+1), 0, 0)
+		currentMembers, err := c.ZRANGEBYSCORE(sourceKey, ZNegInf, ZPosInf, 0, 0)
 		if err != nil {
 			return membersWithScores, err
 		}
 
-		for member, score := range currentSet {
+		for member, score := range zToMap(currentMembers) {
 			if existingValue, ok := membersWithScores[member]; ok {
 				membersWithScores[member] = accumulators[aggregation](existingValue, score*zGetWeight(weights, sourceKey))
 			} else {
@@ -531,19 +1440,23 @@ func (c Client) ZUNION(sourceKeys []string, aggregation ZAggregation, weights ma
 }
 
 func (c Client) ZINTER(sourceKeys []string, aggregation ZAggregation, weights map[string]float64) (membersWithScores map[string]float64, err error) {
-	membersWithScores, err = c.ZRANGEBYSCORE(sourceKeys[0], math.Inf(-1), math.Inf(+1), 0, 0)
+	firstMembers, err := c.ZRANGEBYSCORE(sourceKeys[0], ZNegInf, ZPosInf, 0, 0)
 	if err != nil {
 		return
 	}
 
+	membersWithScores = zToMap(firstMembers)
+
 	for i := 1; i < len(sourceKeys); i++ {
 		sourceKey := sourceKeys[i]
-		currentSet, err := c.ZRANGEBYSCORE(sourceKey, math.Inf(-1), math.Inf(+1), 0, 0)
+		currentMembers, err := c.ZRANGEBYSCORE(sourceKey, ZNegInf, ZPosInf, 0, 0)
 
 		if err != nil {
 			return membersWithScores, err
 		}
 
+		currentSet := zToMap(currentMembers)
+
 		for member, score := range membersWithScores {
 			if currentSetValue, ok := currentSet[member]; ok {
 				membersWithScores[member] = accumulators[aggregation](score, currentSetValue*zGetWeight(weights, sourceKey))
@@ -0,0 +1,344 @@
+package redimo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// The rank index divides the score space of a sorted set into fixed-width buckets and keeps
+// the number of members in each bucket as a counter, in a separate partition. The rank of a
+// member is then the sum of the counters of the buckets before it, plus the members in its own
+// bucket that come before it – so only the counters and one bucket's worth of members are read,
+// instead of every member with a lower score.
+//
+// The counters are updated in the same transaction as the member, so ZADD, ZINCRBY and ZREM
+// read the current score first and make the write conditional on it not having changed.
+
+const zBucketMaxIndex = 1 << 62
+
+func (c Client) zRankIndexEnabled() bool {
+	return c.zBucketWidth > 0
+}
+
+func (c Client) zBucketKey(key string) string {
+	return strings.Join([]string{"_redimo", "zrank", key}, "/")
+}
+
+func (c Client) zBucketIndex(score float64) int64 {
+	index := math.Floor(score / c.zBucketWidth)
+
+	switch {
+	case index >= zBucketMaxIndex:
+		return zBucketMaxIndex
+	case index <= -zBucketMaxIndex:
+		return -zBucketMaxIndex
+	}
+
+	return int64(index)
+}
+
+// zBucketSK encodes the bucket index into a sort key that sorts in the same order as the index.
+func zBucketSK(index int64) string {
+	return fmt.Sprintf("%020d", uint64(index)^(1<<63))
+}
+
+func zBucketIndexFromSK(sk string) int64 {
+	encoded, _ := strconv.ParseUint(sk, 10, 64)
+	return int64(encoded ^ (1 << 63))
+}
+
+func (c Client) zBucketDeltaAction(key string, index int64, delta int64) dynamodb.TransactWriteItem {
+	builder := newExpresionBuilder()
+	builder.clauses["ADD"] = append(builder.clauses["ADD"], fmt.Sprintf("#%v :delta", vk))
+	builder.keys[vk] = struct{}{}
+	builder.values["delta"] = IntValue{delta}.ToAV()
+
+	return dynamodb.TransactWriteItem{
+		Update: &dynamodb.Update{
+			ExpressionAttributeNames:  builder.expressionAttributeNames(),
+			ExpressionAttributeValues: builder.expressionAttributeValues(),
+			Key:                       keyDef{pk: c.zBucketKey(key), sk: zBucketSK(index)}.toAV(c),
+			TableName:                 aws.String(c.table),
+			UpdateExpression:          builder.updateExpression(),
+		},
+	}
+}
+
+// zBuckets loads the member count of every non-empty bucket.
+func (c Client) zBuckets(key string) (buckets map[int64]int64, err error) {
+	buckets = make(map[int64]int64)
+	hasMoreResults := true
+
+	var lastEvaluatedKey map[string]dynamodb.AttributeValue
+
+	for hasMoreResults {
+		builder := newExpresionBuilder()
+		builder.addConditionEquality(c.pk, StringValue{c.zBucketKey(key)})
+
+		resp, err := c.ddbClient.QueryRequest(&dynamodb.QueryInput{
+			ConsistentRead:            aws.Bool(c.consistentReads),
+			ExclusiveStartKey:         lastEvaluatedKey,
+			ExpressionAttributeNames:  builder.expressionAttributeNames(),
+			ExpressionAttributeValues: builder.expressionAttributeValues(),
+			KeyConditionExpression:    builder.conditionExpression(),
+			TableName:                 aws.String(c.table),
+		}).Send(context.TODO())
+
+		if err != nil {
+			return buckets, err
+		}
+
+		for _, item := range resp.Items {
+			parsedItem := parseItem(item, c)
+			if count := parsedItem.val.Int(); count != 0 {
+				buckets[zBucketIndexFromSK(parsedItem.sk)] = count
+			}
+		}
+
+		if len(resp.LastEvaluatedKey) > 0 {
+			lastEvaluatedKey = resp.LastEvaluatedKey
+		} else {
+			hasMoreResults = false
+		}
+	}
+
+	return
+}
+
+// zBucketCount counts the members of a single bucket whose scores match. The query is
+// widened slightly beyond the bucket's bounds to absorb floating point rounding, and the members
+// are then filtered by the bucket they actually belong to.
+func (c Client) zBucketCount(key string, index int64, match func(score float64) bool) (count int64, err error) {
+	margin := c.zBucketWidth * 1e-6
+	low := zScore{float64(index)*c.zBucketWidth - margin}
+	high := zScore{float64(index+1)*c.zBucketWidth + margin}
+
+	members, err := c.zGeneralRange(key, low, high, 0, 0, true, c.skN)
+	if err != nil {
+		return
+	}
+
+	for _, zm := range members {
+		if c.zBucketIndex(zm.Score) == index && match(zm.Score) {
+			count++
+		}
+	}
+
+	return
+}
+
+// zIndexedRank counts the members in the buckets before the member's own, then the members in its bucket with
+// a score up to its own, less the members tied with it that sort after it.
+func (c Client) zIndexedRank(key string, member string, forward bool) (rank int64, ok bool, err error) {
+	number, ok, err := c.zScoreNumber(key, member)
+	if err != nil || !ok {
+		return
+	}
+
+	buckets, err := c.zBuckets(key)
+	if err != nil {
+		return
+	}
+
+	score := zScoreFromAV(zNumber{number}.ToAV())
+	index := c.zBucketIndex(score)
+
+	var before, after int64
+
+	for bucketIndex, bucketCount := range buckets {
+		switch {
+		case bucketIndex < index:
+			before += bucketCount
+		case bucketIndex > index:
+			after += bucketCount
+		}
+	}
+
+	var inBucket int64
+
+	if forward {
+		inBucket, err = c.zBucketCount(key, index, func(s float64) bool { return s <= score })
+	} else {
+		inBucket, err = c.zBucketCount(key, index, func(s float64) bool { return s >= score })
+		before = after
+	}
+
+	if err != nil {
+		return
+	}
+
+	tiesAfter, err := c.zTiesAfter(key, member, number, forward)
+
+	return before + inBucket - 1 - tiesAfter, true, err
+}
+
+func (c Client) zIndexedCount(key string, min, max float64) (count int64, err error) {
+	buckets, err := c.zBuckets(key)
+	if err != nil {
+		return
+	}
+
+	minIndex := c.zBucketIndex(min)
+	maxIndex := c.zBucketIndex(max)
+	inRange := func(s float64) bool { return s >= min && s <= max }
+
+	for bucketIndex, bucketCount := range buckets {
+		if bucketIndex > minIndex && bucketIndex < maxIndex {
+			count += bucketCount
+		}
+	}
+
+	edgeIndexes := []int64{minIndex}
+	if maxIndex != minIndex {
+		edgeIndexes = append(edgeIndexes, maxIndex)
+	}
+
+	for _, index := range edgeIndexes {
+		if _, ok := buckets[index]; !ok {
+			continue
+		}
+
+		edgeCount, err := c.zBucketCount(key, index, inRange)
+		if err != nil {
+			return count, err
+		}
+
+		count += edgeCount
+	}
+
+	return
+}
+
+// zIndexedUpdate sets the score of the member to the one returned by the update function, moving
+// the member between bucket counters as necessary. The update function is given the current score exactly
+// as stored, and can return false to leave the member untouched.
+func (c Client) zIndexedUpdate(key string, member string,
+	update func(oldScore string, exists bool) (newScore string, write bool)) (existed bool, newScore float64, written bool, err error) {
+	for retryCount := 0; retryCount < 5; retryCount++ {
+		resp, err := c.ddbClient.GetItemRequest(&dynamodb.GetItemInput{
+			ConsistentRead: aws.Bool(true),
+			Key:            keyDef{pk: key, sk: member}.toAV(c),
+			TableName:      aws.String(c.table),
+		}).Send(context.TODO())
+		if err != nil {
+			return existed, newScore, false, err
+		}
+
+		oldScoreAV, found := resp.Item[c.skN]
+		oldScore := zScoreFromAV(oldScoreAV)
+
+		score, write := update(aws.StringValue(oldScoreAV.N), found)
+		if !write {
+			return found, oldScore, false, nil
+		}
+
+		builder := newExpresionBuilder()
+		builder.updateSetAV(c.skN, zNumber{score}.ToAV())
+
+		if found {
+			builder.condition(fmt.Sprintf("#%v = :old", c.skN), c.skN)
+			builder.values["old"] = oldScoreAV
+		} else {
+			builder.addConditionNotExists(c.pk)
+		}
+
+		actions := []dynamodb.TransactWriteItem{
+			{
+				Update: &dynamodb.Update{
+					ConditionExpression:       builder.conditionExpression(),
+					ExpressionAttributeNames:  builder.expressionAttributeNames(),
+					ExpressionAttributeValues: builder.expressionAttributeValues(),
+					Key:                       keyDef{pk: key, sk: member}.toAV(c),
+					TableName:                 aws.String(c.table),
+					UpdateExpression:          builder.updateExpression(),
+				},
+			},
+		}
+
+		newIndex := c.zBucketIndex(zScoreFromAV(zNumber{score}.ToAV()))
+
+		switch {
+		case !found:
+			actions = append(actions, c.zBucketDeltaAction(key, newIndex, 1))
+			actions = append(actions, c.cardinalityActions(key, 1)...)
+		case c.zBucketIndex(oldScore) != newIndex:
+			actions = append(actions, c.zBucketDeltaAction(key, c.zBucketIndex(oldScore), -1))
+			actions = append(actions, c.zBucketDeltaAction(key, newIndex, 1))
+		}
+
+		_, err = c.ddbClient.TransactWriteItemsRequest(&dynamodb.TransactWriteItemsInput{
+			TransactItems: actions,
+		}).Send(context.TODO())
+		if err == nil {
+			return found, zScoreFromAV(zNumber{score}.ToAV()), true, nil
+		}
+
+		if !conditionFailureError(err) {
+			return found, oldScore, false, err
+		}
+	}
+
+	return false, 0, false, errors.New("too much contention")
+}
+
+// zIndexedRemove deletes the member along with its contribution to its bucket counter.
+func (c Client) zIndexedRemove(key string, member string) (removed bool, err error) {
+	return c.zIndexedRemoveIf(key, member, func(string) bool { return true })
+}
+
+// zIndexedRemoveIf deletes the member only if the match function accepts its current score, exactly as stored.
+func (c Client) zIndexedRemoveIf(key string, member string, match func(score string) bool) (removed bool, err error) {
+	for retryCount := 0; retryCount < 5; retryCount++ {
+		resp, err := c.ddbClient.GetItemRequest(&dynamodb.GetItemInput{
+			ConsistentRead: aws.Bool(true),
+			Key:            keyDef{pk: key, sk: member}.toAV(c),
+			TableName:      aws.String(c.table),
+		}).Send(context.TODO())
+		if err != nil {
+			return false, err
+		}
+
+		oldScoreAV, found := resp.Item[c.skN]
+		if !found || !match(aws.StringValue(oldScoreAV.N)) {
+			return false, nil
+		}
+
+		builder := newExpresionBuilder()
+		builder.condition(fmt.Sprintf("#%v = :old", c.skN), c.skN)
+		builder.values["old"] = oldScoreAV
+
+		actions := []dynamodb.TransactWriteItem{
+			{
+				Delete: &dynamodb.Delete{
+					ConditionExpression:       builder.conditionExpression(),
+					ExpressionAttributeNames:  builder.expressionAttributeNames(),
+					ExpressionAttributeValues: builder.expressionAttributeValues(),
+					Key:                       keyDef{pk: key, sk: member}.toAV(c),
+					TableName:                 aws.String(c.table),
+				},
+			},
+			c.zBucketDeltaAction(key, c.zBucketIndex(zScoreFromAV(oldScoreAV)), -1),
+		}
+		actions = append(actions, c.cardinalityActions(key, -1)...)
+
+		_, err = c.ddbClient.TransactWriteItemsRequest(&dynamodb.TransactWriteItemsInput{
+			TransactItems: actions,
+		}).Send(context.TODO())
+		if err == nil {
+			return true, nil
+		}
+
+		if !conditionFailureError(err) {
+			return false, err
+		}
+	}
+
+	return false, errors.New("too much contention")
+}
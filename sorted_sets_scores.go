@@ -0,0 +1,191 @@
+package redimo
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// Scores are stored in skN as DynamoDB Numbers, which hold up to 38 significant digits with magnitudes
+// between 1E-130 and 9.9999999999999999999999999999999999999E+125. Float scores are written with the
+// 17 significant digits that identify a float64 exactly, and integer scores written with ZADDINT are stored
+// as they are, so int64 IDs and timestamps keep their exact order.
+
+var (
+	ErrZInvalidScore    = errors.New("score is not a number")
+	ErrZScoreOutOfRange = errors.New("score is outside the range of DynamoDB numbers")
+	ErrNotInteger       = errors.New("value is not an integer or out of range")
+)
+
+const (
+	zMaxMagnitude = 1e126
+	zMinMagnitude = 1e-130
+)
+
+func zValidateScore(score float64) error {
+	magnitude := math.Abs(score)
+
+	switch {
+	case math.IsNaN(score):
+		return ErrZInvalidScore
+	case magnitude >= zMaxMagnitude || magnitude != 0 && magnitude < zMinMagnitude:
+		return ErrZScoreOutOfRange
+	}
+
+	return nil
+}
+
+func zFormatScore(score float64) string {
+	return strconv.FormatFloat(score, 'G', 17, 64)
+}
+
+// zNumber is an exact score, as the decimal string DynamoDB stores.
+type zNumber struct {
+	number string
+}
+
+func (zn zNumber) ToAV() (av dynamodb.AttributeValue) {
+	av.N = aws.String(zn.number)
+	return
+}
+
+func (zn zNumber) present() bool {
+	return true
+}
+
+func (zn zNumber) excludes(member string) bool {
+	return false
+}
+
+func zParseNumber(number string) *big.Rat {
+	r, ok := new(big.Rat).SetString(number)
+	if !ok {
+		return new(big.Rat)
+	}
+
+	return r
+}
+
+func zCompareNumbers(a, b string) int {
+	return zParseNumber(a).Cmp(zParseNumber(b))
+}
+
+func zAddNumbers(a, b string) string {
+	return zFormatRat(new(big.Rat).Add(zParseNumber(a), zParseNumber(b)))
+}
+
+// zAdjacentNumber returns the number closest to the given one, above or below it, that DynamoDB can store. Any
+// stored number beyond the given one is at least as far away, since stored numbers have at most 38 significant
+// digits – so an exclusive bound can be queried as an inclusive bound on the adjacent number.
+func zAdjacentNumber(number string, up bool) string {
+	x := zParseNumber(number)
+
+	stepExponent := -130
+
+	if x.Sign() != 0 {
+		magnitude := new(big.Rat).Abs(x)
+		exponent := zExponent(magnitude)
+		stepExponent = exponent - 37
+
+		// Moving towards zero from a power of ten reaches numbers with one more digit after the point.
+		if up == (x.Sign() < 0) && magnitude.Cmp(zPow10(exponent)) == 0 {
+			stepExponent--
+		}
+	}
+
+	if up {
+		x.Add(x, zPow10(stepExponent))
+	} else {
+		x.Sub(x, zPow10(stepExponent))
+	}
+
+	return zFormatRat(x)
+}
+
+func zPow10(exponent int) *big.Rat {
+	if exponent < 0 {
+		return new(big.Rat).SetFrac(big.NewInt(1), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-exponent)), nil))
+	}
+
+	return new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exponent)), nil))
+}
+
+// zExponent finds the e for which 10^e <= x < 10^(e+1), for a positive x.
+func zExponent(x *big.Rat) int {
+	f, _ := x.Float64()
+	exponent := int(math.Floor(math.Log10(f)))
+
+	for x.Cmp(zPow10(exponent)) < 0 {
+		exponent--
+	}
+
+	for x.Cmp(zPow10(exponent+1)) >= 0 {
+		exponent++
+	}
+
+	return exponent
+}
+
+// zFormatRat formats a number that DynamoDB can store – and so has at most 167 digits after the point – exactly.
+func zFormatRat(x *big.Rat) string {
+	s := x.FloatString(167)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+
+	if s == "-0" {
+		return "0"
+	}
+
+	return s
+}
+
+// ZADDINT is ZADD with integer scores, which are stored exactly. Float scores can only represent integers
+// up to 2^53 exactly, so use ZADDINT for scores like Snowflake IDs or nanosecond timestamps, along with ZSCOREINT,
+// ZIntScoreBound and ZExclusiveIntScoreBound. Range results still report scores as float64s.
+//
+// Works similar to https://redis.io/commands/zadd
+func (c Client) ZADDINT(key string, membersWithScores map[string]int64, flags Flags) (members []string, err error) {
+	numbers := make(map[string]string, len(membersWithScores))
+	for member, score := range membersWithScores {
+		numbers[member] = strconv.FormatInt(score, 10)
+	}
+
+	return c.zAdd(key, numbers, flags)
+}
+
+// ZSCOREINT returns the exact score of the member, which must be an integer that fits in an int64,
+// otherwise ErrNotInteger is returned.
+//
+// Works similar to https://redis.io/commands/zscore
+func (c Client) ZSCOREINT(key string, member string) (score int64, found bool, err error) {
+	number, found, err := c.zScoreNumber(key, member)
+	if err != nil || !found {
+		return
+	}
+
+	score, err = strconv.ParseInt(number, 10, 64)
+	if err != nil {
+		return 0, true, ErrNotInteger
+	}
+
+	return
+}
+
+func (c Client) zScoreNumber(key string, member string) (number string, found bool, err error) {
+	resp, err := c.ddbClient.GetItemRequest(&dynamodb.GetItemInput{
+		ConsistentRead:       aws.Bool(c.consistentReads),
+		Key:                  keyDef{pk: key, sk: member}.toAV(c),
+		ProjectionExpression: aws.String(c.skN),
+		TableName:            aws.String(c.table),
+	}).Send(context.TODO())
+	if err != nil || len(resp.Item) == 0 {
+		return
+	}
+
+	return aws.StringValue(resp.Item[c.skN].N), true, nil
+}
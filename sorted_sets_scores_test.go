@@ -0,0 +1,118 @@
+package redimo
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZAdjacentNumber(t *testing.T) {
+	assert.Equal(t, "5.0000000000000000000000000000000000001", zAdjacentNumber("5", true))
+	assert.Equal(t, "4.9999999999999999999999999999999999999", zAdjacentNumber("5", false))
+	assert.Equal(t, "9.9999999999999999999999999999999999999", zAdjacentNumber("10", false))
+	assert.Equal(t, "-9.9999999999999999999999999999999999999", zAdjacentNumber("-10", true))
+	assert.Equal(t, "-10.000000000000000000000000000000000001", zAdjacentNumber("-10", false))
+	assert.Equal(t, "1700000000000000001.0000000000000000001", zAdjacentNumber("1700000000000000001", true))
+	assert.Equal(t, "0.25000000000000000000000000000000000001", zAdjacentNumber("2.5E-1", true))
+	assert.Equal(t, 0, zCompareNumbers("1E-130", zAdjacentNumber("0", true)))
+	assert.Equal(t, 0, zCompareNumbers("-1E-130", zAdjacentNumber("0", false)))
+
+	assert.Equal(t, "0.3", zAddNumbers("0.1", "0.2"))
+	assert.Equal(t, "9007199254740993", zAddNumbers("9007199254740992", "1"))
+}
+
+func TestZValidateScore(t *testing.T) {
+	assert.NoError(t, zValidateScore(0))
+	assert.NoError(t, zValidateScore(-1.5e125))
+	assert.NoError(t, zValidateScore(1e-130))
+	assert.Equal(t, ErrZInvalidScore, zValidateScore(math.NaN()))
+	assert.Equal(t, ErrZScoreOutOfRange, zValidateScore(math.Inf(+1)))
+	assert.Equal(t, ErrZScoreOutOfRange, zValidateScore(-1e200))
+	assert.Equal(t, ErrZScoreOutOfRange, zValidateScore(1e-200))
+}
+
+func TestZIntScores(t *testing.T) {
+	c := newClient(t)
+
+	_, err := c.ZADD("z1", map[string]float64{"ok": 1, "nan": math.NaN()}, Flags{})
+	assert.Equal(t, ErrZInvalidScore, err)
+
+	_, err = c.ZADD("z1", map[string]float64{"inf": math.Inf(-1)}, Flags{})
+	assert.Equal(t, ErrZScoreOutOfRange, err)
+
+	count, err := c.ZCARD("z1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	base := int64(1700000000000000000)
+
+	addedMembers, err := c.ZADDINT("z1", map[string]int64{"a": base + 1, "b": base + 2, "c": base + 3, "d": base}, Flags{})
+	assert.NoError(t, err)
+	assert.Len(t, addedMembers, 4)
+
+	members, err := c.ZRANGE("z1", 0, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"d", "a", "b", "c"}, zReadKeys(members))
+
+	score, ok, err := c.ZSCOREINT("z1", "b")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, base+2, score)
+
+	rank, ok, err := c.ZRANK("z1", "b")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(2), rank)
+
+	members, err = c.ZRANGEBYSCORE("z1", ZExclusiveIntScoreBound(base+1), ZIntScoreBound(base+3), 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b", "c"}, zReadKeys(members))
+
+	count, err = c.ZCOUNT("z1", ZIntScoreBound(base), ZExclusiveIntScoreBound(base+3))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	bound, err := ParseZScoreBound("(1700000000000000002")
+	assert.NoError(t, err)
+	assert.Equal(t, ZExclusiveIntScoreBound(base+2), bound)
+
+	addedMembers, err = c.ZADDINT("z1", map[string]int64{"a": base + 1, "b": base + 5}, Flags{ReturnChanged})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b"}, addedMembers)
+
+	popped, err := c.ZPOPMAX("z1", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b"}, zReadKeys(popped))
+
+	_, err = c.ZADD("z1", map[string]float64{"f": 1.5}, Flags{})
+	assert.NoError(t, err)
+
+	_, _, err = c.ZSCOREINT("z1", "f")
+	assert.Equal(t, ErrNotInteger, err)
+
+	count, err = c.ZRANGESTORE("z2", "z1", ZRangeArgs{Start: "1700000000000000001", Stop: "+inf", By: ZRangeByScore})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	for member, expected := range map[string]int64{"a": base + 1, "c": base + 3} {
+		score, ok, err = c.ZSCOREINT("z2", member)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, expected, score, member)
+	}
+
+	_, err = c.ZADDINT("z3", map[string]int64{"a": 1}, Flags{})
+	assert.NoError(t, err)
+
+	count, err = c.ZDIFFSTORE("z4", []string{"z1", "z3"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	for member, expected := range map[string]int64{"c": base + 3, "d": base} {
+		score, ok, err = c.ZSCOREINT("z4", member)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, expected, score, member)
+	}
+}
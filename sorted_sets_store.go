@@ -0,0 +1,412 @@
+package redimo
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/oklog/ulid"
+)
+
+// ZUNIONSTORE and ZINTERSTORE are computed in a scratch set, under a key of its own. The sources are read
+// page by page, and each member is written into the scratch set with an update expression that does the
+// aggregation in DynamoDB – ADD for sums, and a conditional SET for minimums and maximums. For intersections
+// the number of sources each member was seen in is kept as well, and only members seen in every source
+// are kept.
+//
+// The scratch set is then compared with the destination, both read in member order: members that are missing
+// or have a different score are written, and members that are no longer present are removed. Each write is
+// conditioned on the member it changes not having changed since it was read, and if one has, the comparison
+// starts over; concurrent changes to members that don't need changing aren't noticed. If everything fits in a
+// single transaction – 100 items, fewer with the rank index or cardinality counters, whose updates share it –
+// the destination is replaced atomically. Larger changes are swapped in over several transactions, new and
+// updated members first and removals last, so a concurrent reader may briefly see stale members alongside the
+// new ones, but never misses a member of the result.
+
+const zSourceCountKey = "zsc"
+
+func (c Client) zStore(destinationKey string, sourceKeys []string, aggregation ZAggregation,
+	weights map[string]float64, intersect bool) (count int64, err error) {
+	scratchKey := strings.Join([]string{"_redimo", "zstore", ulid.MustNew(ulid.Now(), rand.Reader).String()}, "/")
+
+	defer func() {
+		if cleanupErr := c.zDeleteAll(scratchKey); err == nil {
+			err = cleanupErr
+		}
+	}()
+
+	for i, sourceKey := range sourceKeys {
+		if err = c.zAccumulate(scratchKey, sourceKey, i, zGetWeight(weights, sourceKey), aggregation, intersect); err != nil {
+			return
+		}
+	}
+
+	return c.zMergeInto(destinationKey, scratchKey, func(item map[string]dynamodb.AttributeValue) bool {
+		return !intersect || ReturnValue{item[zSourceCountKey]}.Int() == int64(len(sourceKeys))
+	})
+}
+
+// zAccumulate aggregates the members of the source set into the scratch set.
+func (c Client) zAccumulate(scratchKey string, sourceKey string, sourceIndex int,
+	weight float64, aggregation ZAggregation, intersect bool) error {
+	it := c.iterate(sourceKey)
+
+	for {
+		item, ok, err := it.next()
+		if err != nil || !ok {
+			return err
+		}
+
+		score := zScoreFromAV(item[c.skN]) * weight
+		if math.IsNaN(score) {
+			score = 0
+		}
+
+		err = c.zAggregate(scratchKey, parseKey(item, c).sk, score, sourceIndex, aggregation, intersect)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (c Client) zAggregate(scratchKey string, member string, score float64, sourceIndex int,
+	aggregation ZAggregation, intersect bool) error {
+	aggregate := aggregation == ZAggregationSum || sourceIndex > 0
+
+	for _, setScore := range []bool{true, false} {
+		builder := newExpresionBuilder()
+
+		if intersect {
+			// The member must have been seen in every source so far.
+			if sourceIndex == 0 {
+				builder.addConditionNotExists(c.pk)
+			} else {
+				builder.addConditionEquality(zSourceCountKey, IntValue{int64(sourceIndex)})
+			}
+
+			builder.clauses["ADD"] = append(builder.clauses["ADD"], fmt.Sprintf("#%v :one", zSourceCountKey))
+			builder.keys[zSourceCountKey] = struct{}{}
+			builder.values["one"] = IntValue{1}.ToAV()
+		}
+
+		if setScore {
+			switch {
+			case aggregation == ZAggregationSum:
+				builder.clauses["ADD"] = append(builder.clauses["ADD"], fmt.Sprintf("#%v :score", c.skN))
+				builder.keys[c.skN] = struct{}{}
+				builder.values["score"] = zScore{score}.ToAV()
+			default:
+				operator := ">"
+				if aggregation == ZAggregationMax {
+					operator = "<"
+				}
+
+				builder.updateSetAV(c.skN, zScore{score}.ToAV())
+
+				if aggregate {
+					builder.condition(fmt.Sprintf("(attribute_not_exists(#%v) OR #%v %v :%v)",
+						c.pk, c.skN, operator, c.skN), c.pk, c.skN)
+				}
+			}
+		}
+
+		_, err := c.ddbClient.UpdateItemRequest(&dynamodb.UpdateItemInput{
+			ConditionExpression:       builder.conditionExpression(),
+			ExpressionAttributeNames:  builder.expressionAttributeNames(),
+			ExpressionAttributeValues: builder.expressionAttributeValues(),
+			Key:                       keyDef{pk: scratchKey, sk: member}.toAV(c),
+			TableName:                 aws.String(c.table),
+			UpdateExpression:          builder.updateExpression(),
+		}).Send(context.TODO())
+		if !conditionFailureError(err) {
+			return err
+		}
+
+		// The score didn't need changing, but an intersection still has to count the source.
+		if !intersect || aggregation == ZAggregationSum {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// zMergeInto makes the destination set contain exactly the members of the source set accepted by the include
+// function, and returns how many members that is.
+func (c Client) zMergeInto(destinationKey string, sourceKey string,
+	include func(item map[string]dynamodb.AttributeValue) bool) (count int64, err error) {
+	return c.zReplaceWith(destinationKey, func() zSource {
+		it := c.iterate(sourceKey)
+
+		return func() (member string, score string, ok bool, err error) {
+			for {
+				item, ok, err := it.next()
+				if err != nil || !ok {
+					return "", "", false, err
+				}
+
+				if include(item) {
+					return parseKey(item, c).sk, aws.StringValue(item[c.skN].N), true, nil
+				}
+			}
+		}
+	})
+}
+
+// zSource returns the members to store one at a time, in member order, with their scores exactly as stored.
+type zSource func() (member string, score string, ok bool, err error)
+
+// zChange is a change to a member of a set being replaced. The old score is empty if the member is new, and the
+// new score is empty if the member is removed.
+type zChange struct {
+	member   string
+	oldScore string
+	newScore string
+}
+
+// zReplaceWith makes the members of the source the only members of the set at key, and returns how many members
+// that is. Changes that fit in a transaction are made in one; larger ones are swapped in by zSwapIn. The source
+// function is called again to start over if a member changed in between.
+func (c Client) zReplaceWith(key string, source func() zSource) (count int64, err error) {
+	for retryCount := 0; retryCount < 5; retryCount++ {
+		var changes []zChange
+
+		count, err = c.zCompare(key, source(), func(change zChange) bool {
+			changes = append(changes, change)
+
+			return len(changes) <= maxTransactionItems
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		actions := c.zChangeActions(key, changes)
+
+		switch {
+		case len(actions) > maxTransactionItems:
+			count, err = c.zSwapIn(key, source)
+		case len(actions) > 0:
+			_, err = c.ddbClient.TransactWriteItemsRequest(&dynamodb.TransactWriteItemsInput{
+				TransactItems: actions,
+			}).Send(context.TODO())
+		}
+
+		if err == nil {
+			return count, nil
+		}
+
+		if !conditionFailureError(err) {
+			return 0, err
+		}
+	}
+
+	return 0, errors.New("too much contention")
+}
+
+// zSwapIn applies changes that don't fit in a single transaction, in transactions of up to 100 items: first the
+// new and updated members, and then the removals, so a concurrent reader never misses a member of the source but
+// may briefly see stale members alongside it. Each transaction carries its own rank index and counter updates.
+func (c Client) zSwapIn(key string, source func() zSource) (count int64, err error) {
+	for _, removals := range []bool{false, true} {
+		var batch []zChange
+
+		flush := func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+
+			_, err := c.ddbClient.TransactWriteItemsRequest(&dynamodb.TransactWriteItemsInput{
+				TransactItems: c.zChangeActions(key, batch),
+			}).Send(context.TODO())
+			batch = nil
+
+			return err
+		}
+
+		var flushErr error
+
+		count, err = c.zCompare(key, source(), func(change zChange) bool {
+			if (change.newScore == "") != removals {
+				return true
+			}
+
+			batch = append(batch, change)
+
+			if len(c.zChangeActions(key, batch)) > maxTransactionItems {
+				batch = batch[:len(batch)-1]
+				flushErr = flush()
+				batch = []zChange{change}
+			}
+
+			return flushErr == nil
+		})
+		if err == nil {
+			err = flushErr
+		}
+
+		if err == nil {
+			err = flush()
+		}
+
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return count, nil
+}
+
+// zCompare reads the set at key alongside the source, both in member order, and calls visit with each member
+// that has to be added, updated or removed for the set to match the source, until visit returns false. Returns
+// the number of source members read.
+func (c Client) zCompare(key string, source zSource, visit func(change zChange) bool) (count int64, err error) {
+	destination := c.iterate(key)
+
+	member, score, sourceOK, err := source()
+	if err != nil {
+		return
+	}
+
+	destinationItem, destinationOK, err := destination.next()
+	if err != nil {
+		return
+	}
+
+	more := true
+
+	for (sourceOK || destinationOK) && more {
+		destinationMember := parseKey(destinationItem, c).sk
+		destinationScore := aws.StringValue(destinationItem[c.skN].N)
+
+		advanceSource, advanceDestination := false, false
+
+		switch {
+		case sourceOK && (!destinationOK || member < destinationMember):
+			more = visit(zChange{member: member, newScore: score})
+			advanceSource = true
+		case destinationOK && (!sourceOK || destinationMember < member):
+			more = visit(zChange{member: destinationMember, oldScore: destinationScore})
+			advanceDestination = true
+		default:
+			if zCompareNumbers(score, destinationScore) != 0 {
+				more = visit(zChange{member: member, oldScore: destinationScore, newScore: score})
+			}
+
+			advanceSource, advanceDestination = true, true
+		}
+
+		if advanceSource {
+			count++
+
+			if member, score, sourceOK, err = source(); err != nil {
+				return
+			}
+		}
+
+		if advanceDestination {
+			if destinationItem, destinationOK, err = destination.next(); err != nil {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+// zChangeActions returns the transaction actions that apply the changes, each conditioned on the member's
+// score not having changed, along with the updates to the rank index and cardinality counters.
+func (c Client) zChangeActions(key string, changes []zChange) (actions []dynamodb.TransactWriteItem) {
+	bucketDeltas := make(map[int64]int64)
+
+	var cardinalityDelta int64
+
+	for _, change := range changes {
+		builder := newExpresionBuilder()
+		memberKey := keyDef{pk: key, sk: change.member}.toAV(c)
+
+		if change.oldScore == "" {
+			builder.addConditionNotExists(c.pk)
+			cardinalityDelta++
+		} else {
+			builder.addConditionEquality(c.skN, zNumber{change.oldScore})
+			bucketDeltas[c.zBucketIndex(zScoreFromAV(zNumber{change.oldScore}.ToAV()))]--
+		}
+
+		if change.newScore == "" {
+			cardinalityDelta--
+
+			actions = append(actions, dynamodb.TransactWriteItem{
+				Delete: &dynamodb.Delete{
+					ConditionExpression:       builder.conditionExpression(),
+					ExpressionAttributeNames:  builder.expressionAttributeNames(),
+					ExpressionAttributeValues: builder.expressionAttributeValues(),
+					Key:                       memberKey,
+					TableName:                 aws.String(c.table),
+				},
+			})
+
+			continue
+		}
+
+		builder.updateSetAV(c.skN, zNumber{change.newScore}.ToAV())
+		bucketDeltas[c.zBucketIndex(zScoreFromAV(zNumber{change.newScore}.ToAV()))]++
+
+		actions = append(actions, dynamodb.TransactWriteItem{
+			Update: &dynamodb.Update{
+				ConditionExpression:       builder.conditionExpression(),
+				ExpressionAttributeNames:  builder.expressionAttributeNames(),
+				ExpressionAttributeValues: builder.expressionAttributeValues(),
+				Key:                       memberKey,
+				TableName:                 aws.String(c.table),
+				UpdateExpression:          builder.updateExpression(),
+			},
+		})
+	}
+
+	if c.zRankIndexEnabled() {
+		for index, delta := range bucketDeltas {
+			if delta != 0 {
+				actions = append(actions, c.zBucketDeltaAction(key, index, delta))
+			}
+		}
+	}
+
+	return append(actions, c.cardinalityActions(key, cardinalityDelta)...)
+}
+
+// zDeleteAll removes every item in the partition at key.
+func (c Client) zDeleteAll(key string) error {
+	it := c.iterate(key)
+
+	var requests []dynamodb.WriteRequest
+
+	for {
+		item, ok, err := it.next()
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			requests = append(requests, dynamodb.WriteRequest{
+				DeleteRequest: &dynamodb.DeleteRequest{Key: parseKey(item, c).toAV(c)},
+			})
+		}
+
+		if len(requests) == maxBatchWriteItems || !ok && len(requests) > 0 {
+			if err = c.batchWrite(requests); err != nil {
+				return err
+			}
+
+			requests = nil
+		}
+
+		if !ok {
+			return nil
+		}
+	}
+}
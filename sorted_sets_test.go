@@ -1,7 +1,8 @@
 package redimo
 
 import (
-	"math"
+	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -96,7 +97,7 @@ func TestZPops(t *testing.T) {
 
 	membersWithScores, err := c.ZPOPMAX("z1", 2)
 	assert.NoError(t, err)
-	assert.Equal(t, map[string]float64{"m9": 9, "m8": 8}, membersWithScores)
+	assert.Equal(t, []ZMember{{"m9", 9}, {"m8", 8}}, membersWithScores)
 
 	count, err = c.ZCARD("z1")
 	assert.NoError(t, err)
@@ -112,7 +113,7 @@ func TestZPops(t *testing.T) {
 
 	membersWithScores, err = c.ZPOPMIN("z1", 2)
 	assert.NoError(t, err)
-	assert.Equal(t, map[string]float64{"m1": 1, "m2": 2}, membersWithScores)
+	assert.Equal(t, []ZMember{{"m1", 1}, {"m2", 2}}, membersWithScores)
 
 	count, err = c.ZCARD("z1")
 	assert.NoError(t, err)
@@ -128,7 +129,7 @@ func TestZPops(t *testing.T) {
 
 	membersWithScores, err = c.ZPOPMIN("z1", 1000)
 	assert.NoError(t, err)
-	assert.Equal(t, map[string]float64{"m3": 3, "m4": 4, "m5": 5, "m6": 6, "m7": 7}, membersWithScores)
+	assert.Equal(t, []ZMember{{"m3", 3}, {"m4", 4}, {"m5", 5}, {"m6", 6}, {"m7", 7}}, membersWithScores)
 
 	count, err = c.ZCARD("z1")
 	assert.NoError(t, err)
@@ -155,77 +156,79 @@ func TestZRanges(t *testing.T) {
 
 	set, err := c.ZRANGE("z1", 0, 3)
 	assert.NoError(t, err)
-	assert.Equal(t, map[string]float64{"m1": 1, "m2": 2, "m3": 3, "m4": 4}, set)
+	assert.Equal(t, []ZMember{{"m1", 1}, {"m2", 2}, {"m3", 3}, {"m4", 4}}, set)
 
 	set, err = c.ZRANGE("z1", 2, -4)
 	assert.NoError(t, err)
-	assert.Equal(t, map[string]float64{"m3": 3, "m4": 4, "m5": 5, "m6": 6}, set)
+	assert.Equal(t, []ZMember{{"m3", 3}, {"m4", 4}, {"m5", 5}, {"m6", 6}}, set)
 
 	set, err = c.ZRANGE("z1", -4, -1)
 	assert.NoError(t, err)
-	assert.Equal(t, map[string]float64{"m6": 6, "m7": 7, "m8": 8, "m9": 9}, set)
+	assert.Equal(t, []ZMember{{"m6", 6}, {"m7", 7}, {"m8", 8}, {"m9", 9}}, set)
 
 	set, err = c.ZREVRANGE("z1", 0, 3)
 	assert.NoError(t, err)
-	assert.Equal(t, map[string]float64{"m6": 6, "m7": 7, "m8": 8, "m9": 9}, set)
+	assert.Equal(t, []ZMember{{"m9", 9}, {"m8", 8}, {"m7", 7}, {"m6", 6}}, set)
 
 	set, err = c.ZREVRANGE("z1", 0, -1)
 	assert.NoError(t, err)
-	assert.Equal(t, fullSet, set)
+	assert.Equal(t, fullSet, zToMap(set))
+	assert.Equal(t, "m9", set[0].Member)
+	assert.Equal(t, "m1", set[8].Member)
 
-	set, err = c.ZRANGEBYLEX("z1", "m2", "m6", 0, 0)
+	set, err = c.ZRANGEBYLEX("z1", ZLexBound("m2"), ZLexBound("m6"), 0, 0)
 	assert.NoError(t, err)
-	assert.Equal(t, map[string]float64{"m2": 2, "m3": 3, "m4": 4, "m5": 5, "m6": 6}, set)
+	assert.Equal(t, []ZMember{{"m2", 2}, {"m3", 3}, {"m4", 4}, {"m5", 5}, {"m6", 6}}, set)
 
-	set, err = c.ZREVRANGEBYLEX("z1", "m8", "m5", 0, 0)
+	set, err = c.ZREVRANGEBYLEX("z1", ZLexBound("m8"), ZLexBound("m5"), 0, 0)
 	assert.NoError(t, err)
-	assert.Equal(t, map[string]float64{"m8": 8, "m7": 7, "m6": 6, "m5": 5}, set)
+	assert.Equal(t, []ZMember{{"m8", 8}, {"m7", 7}, {"m6", 6}, {"m5", 5}}, set)
 
-	set, err = c.ZRANGEBYSCORE("z1", 2, 6, 0, 0)
+	set, err = c.ZRANGEBYSCORE("z1", ZScoreBound(2), ZScoreBound(6), 0, 0)
 	assert.NoError(t, err)
-	assert.Equal(t, map[string]float64{"m2": 2, "m3": 3, "m4": 4, "m5": 5, "m6": 6}, set)
+	assert.Equal(t, []ZMember{{"m2", 2}, {"m3", 3}, {"m4", 4}, {"m5", 5}, {"m6", 6}}, set)
 
-	set, err = c.ZREVRANGEBYSCORE("z1", 8, 5, 0, 0)
+	set, err = c.ZREVRANGEBYSCORE("z1", ZScoreBound(8), ZScoreBound(5), 0, 0)
 	assert.NoError(t, err)
-	assert.Equal(t, map[string]float64{"m8": 8, "m7": 7, "m6": 6, "m5": 5}, set)
+	assert.Equal(t, []ZMember{{"m8", 8}, {"m7", 7}, {"m6", 6}, {"m5", 5}}, set)
 
-	set, err = c.ZRANGEBYLEX("z1", "m2", "m6", 2, 0)
+	set, err = c.ZRANGEBYLEX("z1", ZLexBound("m2"), ZLexBound("m6"), 2, 0)
 	assert.NoError(t, err)
-	assert.Equal(t, map[string]float64{"m4": 4, "m5": 5, "m6": 6}, set)
+	assert.Equal(t, []ZMember{{"m4", 4}, {"m5", 5}, {"m6", 6}}, set)
 
-	set, err = c.ZREVRANGEBYSCORE("z1", 8, 5, 3, 0)
+	set, err = c.ZREVRANGEBYSCORE("z1", ZScoreBound(8), ZScoreBound(5), 3, 0)
 	assert.NoError(t, err)
-	assert.Equal(t, map[string]float64{"m5": 5}, set)
+	assert.Equal(t, []ZMember{{"m5", 5}}, set)
 
-	set, err = c.ZREVRANGEBYLEX("z1", "m8", "m5", 0, 3)
+	set, err = c.ZREVRANGEBYLEX("z1", ZLexBound("m8"), ZLexBound("m5"), 0, 3)
 	assert.NoError(t, err)
-	assert.Equal(t, map[string]float64{"m8": 8, "m7": 7, "m6": 6}, set)
+	assert.Equal(t, []ZMember{{"m8", 8}, {"m7", 7}, {"m6", 6}}, set)
 
-	set, err = c.ZRANGEBYLEX("z1", "m2", "m6", 2, 2)
+	set, err = c.ZRANGEBYLEX("z1", ZLexBound("m2"), ZLexBound("m6"), 2, 2)
 	assert.NoError(t, err)
-	assert.Equal(t, map[string]float64{"m4": 4, "m5": 5}, set)
+	assert.Equal(t, []ZMember{{"m4", 4}, {"m5", 5}}, set)
 
-	set, err = c.ZRANGEBYSCORE("z1", math.Inf(-1), 3, 0, 0)
+	set, err = c.ZRANGEBYSCORE("z1", ZNegInf, ZScoreBound(3), 0, 0)
 	assert.NoError(t, err)
-	assert.Equal(t, map[string]float64{"m1": 1, "m2": 2, "m3": 3}, set)
+	assert.Equal(t, []ZMember{{"m1", 1}, {"m2", 2}, {"m3", 3}}, set)
 
-	set, err = c.ZREVRANGEBYLEX("z1", "m3", "", 0, 3)
+	set, err = c.ZREVRANGEBYLEX("z1", ZLexBound("m3"), ZNegInf, 0, 3)
 	assert.NoError(t, err)
-	assert.Equal(t, map[string]float64{"m3": 3, "m2": 2, "m1": 1}, set)
+	assert.Equal(t, []ZMember{{"m3", 3}, {"m2", 2}, {"m1", 1}}, set)
 
-	set, err = c.ZREVRANGEBYLEX("z1", "m3", "", 0, 1)
+	set, err = c.ZREVRANGEBYLEX("z1", ZLexBound("m3"), ZNegInf, 0, 1)
 	assert.NoError(t, err)
-	assert.Equal(t, map[string]float64{"m3": 3}, set)
+	assert.Equal(t, []ZMember{{"m3", 3}}, set)
 
-	set, err = c.ZREVRANGEBYLEX("z1", "m3", "", 1, 1)
+	set, err = c.ZREVRANGEBYLEX("z1", ZLexBound("m3"), ZNegInf, 1, 1)
 	assert.NoError(t, err)
-	assert.Equal(t, map[string]float64{"m2": 2}, set)
+	assert.Equal(t, []ZMember{{"m2", 2}}, set)
 
-	removedMembers, err := c.ZREMRANGEBYLEX("z1", "m1", "m3")
+	removedMembers, err := c.ZREMRANGEBYLEX("z1", ZLexBound("m1"), ZLexBound("m3"))
 	assert.NoError(t, err)
 	assert.ElementsMatch(t, []string{"m1", "m2", "m3"}, removedMembers)
 
-	set, err = c.ZRANGEBYLEX("z1", "m1", "m3", 0, 0)
+	set, err = c.ZRANGEBYLEX("z1", ZLexBound("m1"), ZLexBound("m3"), 0, 0)
 	assert.NoError(t, err)
 	assert.Empty(t, set)
 
@@ -246,7 +249,7 @@ func TestZRanges(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, int64(3), count)
 
-	removedMembers, err = c.ZREMRANGEBYSCORE("z1", 7, 9)
+	removedMembers, err = c.ZREMRANGEBYSCORE("z1", ZScoreBound(7), ZScoreBound(9))
 	assert.NoError(t, err)
 	assert.ElementsMatch(t, []string{"m7", "m8", "m9"}, removedMembers)
 
@@ -277,19 +280,19 @@ func TestZCounts(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 4, len(addedMembers))
 
-	count, err := c.ZCOUNT("z1", 2, 3)
+	count, err := c.ZCOUNT("z1", ZScoreBound(2), ZScoreBound(3))
 	assert.NoError(t, err)
 	assert.Equal(t, int64(2), count)
 
-	count, err = c.ZCOUNT("z1", 2, math.Inf(+1))
+	count, err = c.ZCOUNT("z1", ZScoreBound(2), ZPosInf)
 	assert.NoError(t, err)
 	assert.Equal(t, int64(3), count)
 
-	count, err = c.ZCOUNT("z1", math.Inf(-1), math.Inf(+1))
+	count, err = c.ZCOUNT("z1", ZNegInf, ZPosInf)
 	assert.NoError(t, err)
 	assert.Equal(t, int64(4), count)
 
-	count, err = c.ZLEXCOUNT("z1", "m2", "m4")
+	count, err = c.ZLEXCOUNT("z1", ZLexBound("m2"), ZLexBound("m4"))
 	assert.NoError(t, err)
 	assert.Equal(t, int64(3), count)
 
@@ -350,13 +353,16 @@ func TestZAggregations(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, map[string]float64{"m1": 1, "m2": 2, "m3": 7, "m4": 8, "m5": 10, "m6": 6, "m7": 7}, set)
 
-	set, err = c.ZUNIONSTORE("union1", []string{"z1", "z2", "z3"}, ZAggregationMax, map[string]float64{"z2": 2})
+	_, err = c.ZADD("union1", map[string]float64{"stale": 1, "m1": 100}, Flags{})
 	assert.NoError(t, err)
-	assert.Equal(t, 7, len(set))
 
-	set, err = c.ZRANGEBYSCORE("union1", math.Inf(-1), math.Inf(+1), 0, 0)
+	count, err := c.ZUNIONSTORE("union1", []string{"z1", "z2", "z3"}, ZAggregationMax, map[string]float64{"z2": 2})
 	assert.NoError(t, err)
-	assert.Equal(t, map[string]float64{"m1": 1, "m2": 2, "m3": 7, "m4": 8, "m5": 10, "m6": 6, "m7": 7}, set)
+	assert.Equal(t, int64(7), count)
+
+	members, err := c.ZRANGEBYSCORE("union1", ZNegInf, ZPosInf, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"m1", 1}, {"m2", 2}, {"m6", 6}, {"m3", 7}, {"m7", 7}, {"m4", 8}, {"m5", 10}}, members)
 
 	set, err = c.ZINTER([]string{"z1", "z3"}, ZAggregationSum, nil)
 	assert.NoError(t, err)
@@ -378,11 +384,643 @@ func TestZAggregations(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, map[string]float64{"m3": 7}, set)
 
-	set, err = c.ZINTERSTORE("inter1", []string{"z1", "z2"}, ZAggregationMax, map[string]float64{"z2": 2})
+	count, err = c.ZINTERSTORE("inter1", []string{"z1", "z2"}, ZAggregationMax, map[string]float64{"z2": 2})
 	assert.NoError(t, err)
-	assert.Equal(t, 1, len(set))
+	assert.Equal(t, int64(1), count)
 
-	set, err = c.ZRANGEBYSCORE("inter1", math.Inf(-1), math.Inf(+1), 0, 0)
+	members, err = c.ZRANGEBYSCORE("inter1", ZNegInf, ZPosInf, 0, 0)
 	assert.NoError(t, err)
-	assert.Equal(t, map[string]float64{"m3": 7}, set)
+	assert.Equal(t, []ZMember{{"m3", 7}}, members)
+}
+
+func TestZRangeTies(t *testing.T) {
+	c := newClient(t)
+
+	_, err := c.ZADD("z1", map[string]float64{"c": 1, "a": 1, "e": 2, "b": 1, "d": 0}, Flags{})
+	assert.NoError(t, err)
+
+	members, err := c.ZRANGE("z1", 0, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"d", 0}, {"a", 1}, {"b", 1}, {"c", 1}, {"e", 2}}, members)
+
+	members, err = c.ZREVRANGE("z1", 0, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"e", 2}, {"c", 1}, {"b", 1}, {"a", 1}, {"d", 0}}, members)
+
+	members, err = c.ZRANGE("z1", 0, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"d", 0}, {"a", 1}}, members)
+
+	members, err = c.ZRANGEBYSCORE("z1", ZScoreBound(1), ZScoreBound(1), 1, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"b", 1}}, members)
+
+	members, err = c.ZRANGE("z1", 1, -3)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"a", 1}, {"b", 1}}, members)
+
+	members, err = c.ZREVRANGE("z1", 1, -3)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"c", 1}, {"b", 1}}, members)
+
+	members, err = c.ZRANGE("z1", -2, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"c", 1}, {"e", 2}}, members)
+
+	members, err = c.ZREVRANGE("z1", -2, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"a", 1}, {"d", 0}}, members)
+
+	members, err = c.ZRANGE("z1", -10, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"d", 0}}, members)
+
+	members, err = c.ZRANGEBYSCORE("z1", ZNegInf, ZPosInf, -1, 2)
+	assert.NoError(t, err)
+	assert.Empty(t, members)
+
+	members, err = c.ZRANGEBYLEX("z1", ZNegInf, ZPosInf, -1, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, members)
+
+	members, err = c.ZPOPMIN("z1", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"d", 0}, {"a", 1}}, members)
+
+	members, err = c.ZPOPMAX("z1", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"e", 2}, {"c", 1}}, members)
+}
+
+func TestZRankIndex(t *testing.T) {
+	plain := newClient(t)
+	indexed := plain.ZRankIndex(10)
+
+	members := map[string]float64{}
+	for i := 0; i < 50; i++ {
+		members[fmt.Sprintf("m%02d", i)] = float64(i*3 - 40)
+	}
+
+	addedMembers, err := indexed.ZADD("z1", members, Flags{})
+	assert.NoError(t, err)
+	assert.Len(t, addedMembers, 50)
+
+	addedMembers, err = indexed.ZADD("z1", map[string]float64{"m00": 500, "m99": 5}, Flags{IfNotExists})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"m99"}, addedMembers)
+
+	newScore, err := indexed.ZINCRBY("z1", "m10", 25.5)
+	assert.NoError(t, err)
+	assert.Equal(t, 15.5, newScore)
+
+	removedMembers, err := indexed.ZREM("z1", "m20", "m30", "nope")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"m20", "m30"}, removedMembers)
+
+	popped, err := indexed.ZPOPMIN("z1", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"m00", -40}}, popped)
+
+	for _, member := range []string{"m01", "m10", "m25", "m49", "m99"} {
+		expectedRank, ok, err := plain.ZRANK("z1", member)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		rank, ok, err := indexed.ZRANK("z1", member)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, expectedRank, rank, member)
+
+		expectedRank, _, err = plain.ZREVRANK("z1", member)
+		assert.NoError(t, err)
+
+		rank, _, err = indexed.ZREVRANK("z1", member)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedRank, rank, member)
+	}
+
+	_, ok, err := indexed.ZRANK("z1", "m00")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	for _, bounds := range [][2]float64{{-100, 200}, {-10, 10}, {0, 9.99}, {5, 5}, {15.5, 60}, {200, 300}} {
+		expectedCount, err := plain.ZCOUNT("z1", ZScoreBound(bounds[0]), ZScoreBound(bounds[1]))
+		assert.NoError(t, err)
+
+		count, err := indexed.ZCOUNT("z1", ZScoreBound(bounds[0]), ZScoreBound(bounds[1]))
+		assert.NoError(t, err)
+		assert.Equal(t, expectedCount, count, bounds)
+	}
+}
+
+func TestZRankTies(t *testing.T) {
+	plain := newClient(t)
+
+	for name, c := range map[string]Client{"plain": plain, "indexed": plain.ZRankIndex(10)} {
+		_, err := c.ZADD(name, map[string]float64{"a": 1, "b": 1, "c": 1, "d": 0, "e": 2}, Flags{})
+		assert.NoError(t, err)
+
+		for member, expected := range map[string][2]int64{"d": {0, 4}, "a": {1, 3}, "b": {2, 2}, "c": {3, 1}, "e": {4, 0}} {
+			rank, ok, err := c.ZRANK(name, member)
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, expected[0], rank, name, member)
+
+			rank, ok, err = c.ZREVRANK(name, member)
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, expected[1], rank, name, member)
+		}
+	}
+}
+
+func TestZAddOptions(t *testing.T) {
+	plain := newClient(t)
+
+	for name, c := range map[string]Client{"plain": plain, "indexed": plain.ZRankIndex(10)} {
+		_, err := c.ZADD(name, map[string]float64{"m1": 10, "m2": 20}, Flags{})
+		assert.NoError(t, err, name)
+
+		members, err := c.ZADD(name, map[string]float64{"m1": 15, "m2": 5, "m3": 30}, Flags{IfGreaterThan})
+		assert.NoError(t, err, name)
+		assert.Equal(t, []string{"m3"}, members, name)
+		assertZScore(t, c, name, "m1", 15)
+		assertZScore(t, c, name, "m2", 20)
+
+		members, err = c.ZADD(name, map[string]float64{"m1": 12, "m2": 25, "m3": 30}, Flags{IfLessThan, ReturnChanged})
+		assert.NoError(t, err, name)
+		assert.Equal(t, []string{"m1"}, members, name)
+		assertZScore(t, c, name, "m1", 12)
+		assertZScore(t, c, name, "m2", 20)
+
+		members, err = c.ZADD(name, map[string]float64{"m1": 12, "m2": 21, "m4": 40}, Flags{ReturnChanged})
+		assert.NoError(t, err, name)
+		assert.ElementsMatch(t, []string{"m2", "m4"}, members, name)
+
+		members, err = c.ZADD(name, map[string]float64{"m5": 50, "m1": 1}, Flags{IfAlreadyExists, IfGreaterThan, ReturnChanged})
+		assert.NoError(t, err, name)
+		assert.Empty(t, members, name)
+
+		_, err = c.ZADD(name, map[string]float64{"m1": 1}, Flags{IfNotExists, IfLessThan})
+		assert.Equal(t, ErrZIncompatibleFlags, err, name)
+
+		newScore, ok, err := c.ZADDINCR(name, "m1", 3, Flags{IfGreaterThan})
+		assert.NoError(t, err, name)
+		assert.True(t, ok, name)
+		assert.Equal(t, 15.0, newScore, name)
+
+		_, ok, err = c.ZADDINCR(name, "m1", -3, Flags{IfGreaterThan})
+		assert.NoError(t, err, name)
+		assert.False(t, ok, name)
+
+		newScore, ok, err = c.ZADDINCR(name, "m6", -3, Flags{IfGreaterThan})
+		assert.NoError(t, err, name)
+		assert.True(t, ok, name)
+		assert.Equal(t, -3.0, newScore, name)
+
+		_, ok, err = c.ZADDINCR(name, "m7", 1, Flags{IfAlreadyExists})
+		assert.NoError(t, err, name)
+		assert.False(t, ok, name)
+
+		_, ok, err = c.ZADDINCR(name, "m1", 1, Flags{IfNotExists})
+		assert.NoError(t, err, name)
+		assert.False(t, ok, name)
+
+		count, err := c.ZCARD(name)
+		assert.NoError(t, err, name)
+		assert.Equal(t, int64(6), count, name)
+	}
+}
+
+func assertZScore(t *testing.T, c Client, key string, member string, expected float64) {
+	score, ok, err := c.ZSCORE(key, member)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, expected, score, member)
+}
+
+func TestZRangeArgs(t *testing.T) {
+	c := newClient(t)
+
+	_, err := c.ZADD("z1", map[string]float64{"a": 1, "b": 1.5, "c": 2, "d": 2, "e": 3, "f": 4}, Flags{})
+	assert.NoError(t, err)
+
+	members, err := c.ZRANGEARGS("z1", ZRangeArgs{Start: "(1.5", Stop: "+inf", By: ZRangeByScore})
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"c", 2}, {"d", 2}, {"e", 3}, {"f", 4}}, members)
+
+	members, err = c.ZRANGEARGS("z1", ZRangeArgs{Start: "-inf", Stop: "(2", By: ZRangeByScore})
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"a", 1}, {"b", 1.5}}, members)
+
+	members, err = c.ZRANGEARGS("z1", ZRangeArgs{Start: "(4", Stop: "(1", By: ZRangeByScore, Rev: true, Offset: 1, Count: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"d", 2}, {"c", 2}}, members)
+
+	members, err = c.ZRANGEARGS("z1", ZRangeArgs{Start: "(2", Stop: "(2", By: ZRangeByScore})
+	assert.NoError(t, err)
+	assert.Empty(t, members)
+
+	members, err = c.ZRANGEARGS("z1", ZRangeArgs{Start: "(b", Stop: "[d", By: ZRangeByLex})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c", "d"}, zReadKeys(members))
+
+	members, err = c.ZRANGEARGS("z1", ZRangeArgs{Start: "(e", Stop: "-", By: ZRangeByLex, Rev: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"d", "c", "b", "a"}, zReadKeys(members))
+
+	members, err = c.ZRANGEARGS("z1", ZRangeArgs{Start: "[b", Stop: "(f", By: ZRangeByLex, Offset: 1, Count: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c", "d", "e"}, zReadKeys(members))
+
+	members, err = c.ZRANGEARGS("z1", ZRangeArgs{Start: "0", Stop: "1", Rev: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"f", 4}, {"e", 3}}, members)
+
+	members, err = c.ZRANGEARGS("z1", ZRangeArgs{Start: "0", Stop: "-2"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, zReadKeys(members))
+
+	members, err = c.ZRANGEARGS("z1", ZRangeArgs{Start: "0", Stop: "-2", Rev: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"f", "e", "d", "c", "b"}, zReadKeys(members))
+
+	members, err = c.ZRANGEARGS("z1", ZRangeArgs{Start: "-2", Stop: "10"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"e", "f"}, zReadKeys(members))
+
+	members, err = c.ZRANGEARGS("z1", ZRangeArgs{Start: "-10", Stop: "-5"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, zReadKeys(members))
+
+	for _, ranks := range [][2]string{{"5", "2"}, {"-1", "-3"}, {"4", "-3"}, {"6", "10"}, {"0", "-7"}} {
+		members, err = c.ZRANGEARGS("z1", ZRangeArgs{Start: ranks[0], Stop: ranks[1]})
+		assert.NoError(t, err, ranks)
+		assert.Empty(t, members, ranks)
+	}
+
+	members, err = c.ZRANGEARGS("z1", ZRangeArgs{Start: "-inf", Stop: "+inf", By: ZRangeByScore, Offset: -1, Count: 2})
+	assert.NoError(t, err)
+	assert.Empty(t, members)
+
+	_, err = c.ZRANGEARGS("z1", ZRangeArgs{Start: "0", Stop: "1", Count: 1})
+	assert.Equal(t, ErrZSyntax, err)
+
+	_, err = c.ZRANGEARGS("z1", ZRangeArgs{Start: "a", Stop: "+", By: ZRangeByLex})
+	assert.Equal(t, ErrZInvalidBound, err)
+
+	_, err = c.ZRANGEARGS("z1", ZRangeArgs{Start: "(x", Stop: "1", By: ZRangeByScore})
+	assert.Equal(t, ErrZInvalidBound, err)
+
+	_, err = c.ZADD("z2", map[string]float64{"stale": 100, "c": 100}, Flags{})
+	assert.NoError(t, err)
+
+	count, err := c.ZRANGESTORE("z2", "z1", ZRangeArgs{Start: "2", Stop: "3", By: ZRangeByScore})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	members, err = c.ZRANGE("z2", 0, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"c", 2}, {"d", 2}, {"e", 3}}, members)
+
+	count, err = c.ZRANGESTORE("z2", "z1", ZRangeArgs{Start: "10", Stop: "20", By: ZRangeByScore})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	count, err = c.ZCARD("z2")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestZBounds(t *testing.T) {
+	c := newClient(t)
+
+	_, err := c.ZADD("z1", map[string]float64{"a": 1, "b": 2, "c": 2, "d": 3, "e": 4}, Flags{})
+	assert.NoError(t, err)
+
+	count, err := c.ZCOUNT("z1", ZExclusiveScoreBound(1), ZScoreBound(3))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	indexed := c.ZRankIndex(1)
+	_, err = indexed.ZADD("z2", map[string]float64{"a": 1, "b": 2, "c": 2, "d": 3, "e": 4}, Flags{})
+	assert.NoError(t, err)
+
+	count, err = indexed.ZCOUNT("z2", ZExclusiveScoreBound(1), ZExclusiveScoreBound(3))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	count, err = c.ZCOUNT("z1", ZScoreBound(3), ZScoreBound(2))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	count, err = c.ZLEXCOUNT("z1", ZExclusiveLexBound("a"), ZExclusiveLexBound("d"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	count, err = c.ZLEXCOUNT("z1", ZNegInf, ZExclusiveLexBound("bb"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	count, err = c.ZLEXCOUNT("z1", ZLexBound("c"), ZPosInf)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	members, err := c.ZREVRANGEBYSCORE("z1", ZExclusiveScoreBound(4), ZExclusiveScoreBound(1), 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"d", 3}, {"c", 2}, {"b", 2}}, members)
+
+	members, err = c.ZREVRANGEBYLEX("z1", ZExclusiveLexBound("e"), ZExclusiveLexBound("a"), 1, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c", "b"}, zReadKeys(members))
+
+	removedMembers, err := c.ZREMRANGEBYSCORE("z1", ZExclusiveScoreBound(2), ZPosInf)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"d", "e"}, removedMembers)
+
+	removedMembers, err = c.ZREMRANGEBYLEX("z1", ZNegInf, ZExclusiveLexBound("c"))
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a", "b"}, removedMembers)
+
+	members, err = c.ZRANGE("z1", 0, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"c", 2}}, members)
+
+	bound, err := ParseZScoreBound("(2.5")
+	assert.NoError(t, err)
+	assert.Equal(t, ZExclusiveScoreBound(2.5), bound)
+
+	bound, err = ParseZScoreBound("-inf")
+	assert.NoError(t, err)
+	assert.Equal(t, ZNegInf, bound)
+
+	bound, err = ParseZLexBound("+")
+	assert.NoError(t, err)
+	assert.Equal(t, ZPosInf, bound)
+
+	_, err = ParseZLexBound("c")
+	assert.Equal(t, ErrZInvalidBound, err)
+}
+
+func TestZMoreCommands(t *testing.T) {
+	c := newClient(t)
+
+	_, err := c.ZADD("z1", map[string]float64{"a": 1, "b": 2, "c": 3, "d": 4}, Flags{})
+	assert.NoError(t, err)
+	_, err = c.ZADD("z2", map[string]float64{"b": 20, "x": 30}, Flags{})
+	assert.NoError(t, err)
+	_, err = c.ZADD("z3", map[string]float64{"d": 40}, Flags{})
+	assert.NoError(t, err)
+
+	scores, err := c.ZMSCORE("z1", "a", "d", "nope", "a")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float64{"a": 1, "d": 4}, scores)
+
+	members, err := c.ZRANDMEMBER("z1", 3)
+	assert.NoError(t, err)
+	assert.Len(t, members, 3)
+
+	for _, zm := range members {
+		assert.Equal(t, scores[zm.Member], zm.Score)
+	}
+
+	members, err = c.ZRANDMEMBER("z1", 10)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a", "b", "c", "d"}, zReadKeys(members))
+
+	members, err = c.ZRANDMEMBER("z1", -10)
+	assert.NoError(t, err)
+	assert.Len(t, members, 10)
+
+	members, err = c.ZRANDMEMBER("nope", 1)
+	assert.NoError(t, err)
+	assert.Empty(t, members)
+
+	diff, err := c.ZDIFF([]string{"z1", "z2", "z3"})
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"a", 1}, {"c", 3}}, diff)
+
+	_, err = c.ZADD("ties", map[string]float64{"q": 2, "p": 2, "r": 1, "x": 0}, Flags{})
+	assert.NoError(t, err)
+
+	diff, err = c.ZDIFF([]string{"ties", "z2"})
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"r", 1}, {"p", 2}, {"q", 2}}, diff)
+
+	_, err = c.ZADD("z4", map[string]float64{"stale": 1}, Flags{})
+	assert.NoError(t, err)
+
+	count, err := c.ZDIFFSTORE("z4", []string{"z2", "z1"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	members, err = c.ZRANGE("z4", 0, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"x", 30}}, members)
+
+	key, members, err := c.ZMPOP([]string{"nope", "z3", "z1"}, ZMin, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "z3", key)
+	assert.Equal(t, []ZMember{{"d", 40}}, members)
+
+	key, members, err = c.ZMPOP([]string{"nope", "z3", "z1"}, ZMax, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "z1", key)
+	assert.Equal(t, []ZMember{{"d", 4}, {"c", 3}}, members)
+
+	key, members, err = c.ZMPOP([]string{"nope", "z3"}, ZMax, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "", key)
+	assert.Empty(t, members)
+}
+
+func TestZPopConcurrent(t *testing.T) {
+	c := newClient(t)
+
+	members := make(map[string]float64)
+	for i := 0; i < 100; i++ {
+		members[fmt.Sprintf("m%03d", i)] = float64(i % 10)
+	}
+
+	_, err := c.ZADD("queue", members, Flags{})
+	assert.NoError(t, err)
+
+	var (
+		wg     sync.WaitGroup
+		mutex  sync.Mutex
+		popped []string
+	)
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func(forward bool) {
+			defer wg.Done()
+
+			for {
+				pop := c.ZPOPMIN
+				if !forward {
+					pop = c.ZPOPMAX
+				}
+
+				membersWithScores, err := pop("queue", 3)
+				assert.NoError(t, err)
+
+				if len(membersWithScores) == 0 {
+					return
+				}
+
+				mutex.Lock()
+				popped = append(popped, zReadKeys(membersWithScores)...)
+				mutex.Unlock()
+			}
+		}(i%2 == 0)
+	}
+
+	wg.Wait()
+
+	assert.Len(t, popped, 100)
+
+	unique := make(map[string]struct{})
+	for _, member := range popped {
+		unique[member] = struct{}{}
+	}
+
+	assert.Len(t, unique, 100)
+}
+
+func TestZStores(t *testing.T) {
+	c := newClient(t)
+
+	first := make(map[string]float64)
+	second := make(map[string]float64)
+
+	for i := 0; i < 60; i++ {
+		first[fmt.Sprintf("m%02d", i)] = float64(i)
+
+		if i%2 == 0 {
+			second[fmt.Sprintf("m%02d", i)] = float64(100 - i)
+		}
+	}
+
+	_, err := c.ZADD("z1", first, Flags{})
+	assert.NoError(t, err)
+	_, err = c.ZADD("z2", second, Flags{})
+	assert.NoError(t, err)
+
+	count, err := c.ZINTERSTORE("dst", []string{"z1", "z2"}, ZAggregationSum, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(30), count)
+
+	count, err = c.ZCOUNT("dst", ZScoreBound(100), ZScoreBound(100))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(30), count)
+
+	count, err = c.ZINTERSTORE("dst", []string{"z1", "z2"}, ZAggregationMin, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(30), count)
+
+	members, err := c.ZRANGE("dst", -2, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"m52", 48}, {"m50", 50}}, members)
+
+	count, err = c.ZUNIONSTORE("dst", []string{"dst", "z2"}, ZAggregationMax, map[string]float64{"z2": 0.5})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(30), count)
+
+	score, ok, err := c.ZSCORE("dst", "m50")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 50.0, score)
+
+	score, ok, err = c.ZSCORE("dst", "m00")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 50.0, score)
+
+	count, err = c.ZINTERSTORE("dst", []string{"z1", "nope"}, ZAggregationSum, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	count, err = c.ZCARD("dst")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	third := make(map[string]float64)
+	for i := 0; i < 60; i++ {
+		third[fmt.Sprintf("n%02d", i)] = float64(i)
+	}
+
+	_, err = c.ZADD("z3", third, Flags{})
+	assert.NoError(t, err)
+
+	counted := c.CardinalityCounters().ZRankIndex(10)
+
+	count, err = counted.ZUNIONSTORE("big", []string{"z1", "z3"}, ZAggregationSum, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(120), count)
+
+	count, err = counted.ZCARD("big")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(120), count)
+
+	rank, ok, err := counted.ZRANK("big", "n59")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(119), rank)
+
+	// Swapping every member for another is 120 changes, more than a transaction holds.
+	count, err = counted.ZUNIONSTORE("big", []string{"z3"}, ZAggregationSum, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(60), count)
+
+	members, err = counted.ZRANGE("big", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"n00", 0}}, members)
+
+	count, err = counted.ZCARD("big")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(60), count)
+
+	rank, ok, err = counted.ZRANK("big", "n59")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(59), rank)
+}
+
+func TestZCardinality(t *testing.T) {
+	counted := newClient(t).CardinalityCounters()
+
+	for name, c := range map[string]Client{"plain": counted, "indexed": counted.ZRankIndex(10)} {
+		_, err := c.ZADD(name, map[string]float64{"m1": 1, "m2": 2, "m3": 3}, Flags{})
+		assert.NoError(t, err, name)
+
+		_, err = c.ZADD(name, map[string]float64{"m1": 10, "m4": 4}, Flags{})
+		assert.NoError(t, err, name)
+
+		_, err = c.ZINCRBY(name, "m5", 5)
+		assert.NoError(t, err, name)
+
+		newScore, err := c.ZINCRBY(name, "m5", 0.5)
+		assert.NoError(t, err, name)
+		assert.Equal(t, 5.5, newScore, name)
+
+		_, err = c.ZREM(name, "m2", "nosuchmember")
+		assert.NoError(t, err, name)
+
+		_, err = c.ZPOPMIN(name, 1)
+		assert.NoError(t, err, name)
+
+		count, err := c.ZCARD(name)
+		assert.NoError(t, err, name)
+		assert.Equal(t, int64(3), count, name)
+
+		count, err = c.ZUNIONSTORE(name+"dst", []string{name}, ZAggregationSum, nil)
+		assert.NoError(t, err, name)
+		assert.Equal(t, int64(3), count, name)
+
+		count, err = c.ZCARD(name + "dst")
+		assert.NoError(t, err, name)
+		assert.Equal(t, int64(3), count, name)
+	}
 }
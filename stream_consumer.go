@@ -0,0 +1,250 @@
+package redimo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNoStreamHandler is returned by StreamConsumer.Run when no Handler is set.
+	ErrNoStreamHandler = errors.New("stream consumer has no handler")
+
+	// ErrInvalidClaimIdle is returned by StreamConsumer.Run when ClaimIdle is negative, or positive but
+	// shorter than a second – delivery times are only kept to the second, so a shorter idle time would claim
+	// items that are still being handled.
+	ErrInvalidClaimIdle = errors.New("stream consumer claim idle time must be zero or at least a second")
+)
+
+// StreamHandler processes a single stream item delivered to a StreamConsumer. Returning nil
+// acknowledges the item, returning an error leaves it pending so that it is delivered again.
+type StreamHandler func(ctx context.Context, item StreamItem) error
+
+// StreamConsumer runs a pool of workers that read a stream as members of a consumer group,
+// wrapping the XREADGROUP, XACK and XCLAIM loop that every stream consumer needs.
+//
+// Each worker registers as its own consumer, named after the Consumer field and the worker's number.
+// Workers first retry their own pending items and then read new ones, calling the Handler for each item
+// and acknowledging it with XACK when the handler succeeds. Items that keep failing are retried until the
+// DeadLetter policy, if given, moves them to the dead-letter stream.
+//
+// When ClaimIdle is set, pending items that haven't been delivered for longer than ClaimIdle – usually
+// because the consumer that was handling them crashed – are periodically claimed by the workers with XCLAIM.
+//
+// The group must already be created with XGROUP. A StreamConsumer is usable as
+//
+//	err := StreamConsumer{
+//		Client:   client,
+//		Key:      "orders",
+//		Group:    "billing",
+//		Consumer: hostname,
+//		Workers:  4,
+//		Handler:  handleOrder,
+//	}.Run(ctx)
+type StreamConsumer struct {
+	Client   Client
+	Key      string
+	Group    string
+	Consumer string
+	Workers  int
+	Handler  StreamHandler
+
+	// PollInterval is how long a worker waits when there are no items to read, or after a failure.
+	// Defaults to one second.
+	PollInterval time.Duration
+
+	// ClaimIdle is how long an item may stay pending without being re-delivered before the workers
+	// claim it. Zero disables claiming, and otherwise it must be at least a second, as delivery times are kept
+	// to the second. Claiming is checked every ClaimIdle / 2.
+	ClaimIdle time.Duration
+
+	// DeadLetter is applied to the group with XGROUPSETDEADLETTER when the consumer starts,
+	// unless its MaxDeliveries is zero.
+	DeadLetter XDeadLetterPolicy
+
+	// OnError, if set, is called with the errors encountered by the workers and the claimer,
+	// including the errors returned by the Handler. The workers keep running after an error.
+	OnError func(err error)
+}
+
+// Run starts the workers and blocks until the context is cancelled and every worker has stopped.
+// Items that are being handled when the context is cancelled are allowed to finish, and are acknowledged
+// if their handler succeeds.
+//
+// Returns ErrXGroupNotInitialized if the group has not been created, ErrNoStreamHandler or ErrInvalidClaimIdle
+// if the consumer is misconfigured, and nil after a clean shutdown.
+func (sc StreamConsumer) Run(ctx context.Context) error {
+	if sc.Handler == nil {
+		return ErrNoStreamHandler
+	}
+
+	if sc.ClaimIdle < 0 || sc.ClaimIdle > 0 && sc.ClaimIdle < time.Second {
+		return ErrInvalidClaimIdle
+	}
+
+	if sc.DeadLetter.MaxDeliveries > 0 {
+		if err := sc.Client.XGROUPSETDEADLETTER(sc.Key, sc.Group, sc.DeadLetter); err != nil {
+			return err
+		}
+	} else if _, err := sc.Client.xGroupCursorGet(sc.Key, sc.Group); err != nil {
+		return err
+	}
+
+	workers := sc.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func(consumer string) {
+			defer wg.Done()
+			sc.work(ctx, consumer)
+		}(sc.workerName(i))
+	}
+
+	if sc.ClaimIdle > 0 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			sc.claim(ctx, workers)
+		}()
+	}
+
+	wg.Wait()
+
+	return nil
+}
+
+func (sc StreamConsumer) workerName(i int) string {
+	return fmt.Sprintf("%v-%d", sc.Consumer, i)
+}
+
+func (sc StreamConsumer) pollInterval() time.Duration {
+	if sc.PollInterval <= 0 {
+		return time.Second
+	}
+
+	return sc.PollInterval
+}
+
+func (sc StreamConsumer) work(ctx context.Context, consumer string) {
+	for ctx.Err() == nil {
+		items, err := sc.Client.XREADGROUP(sc.Key, sc.Group, consumer, XReadPending, 1)
+		if err == nil && len(items) == 0 {
+			items, err = sc.Client.XREADGROUP(sc.Key, sc.Group, consumer, XReadNew, 1)
+		}
+
+		if err != nil {
+			sc.report(err)
+			sc.wait(ctx)
+
+			continue
+		}
+
+		if len(items) == 0 {
+			sc.wait(ctx)
+			continue
+		}
+
+		for _, item := range items {
+			if !sc.handle(ctx, item) {
+				sc.wait(ctx)
+			}
+		}
+	}
+}
+
+func (sc StreamConsumer) handle(ctx context.Context, item StreamItem) (ok bool) {
+	if err := sc.Handler(ctx, item); err != nil {
+		sc.report(fmt.Errorf("could not handle stream item %v: %w", item.ID, err))
+		return false
+	}
+
+	if _, err := sc.Client.XACK(sc.Key, sc.Group, item.ID); err != nil {
+		sc.report(err)
+		return false
+	}
+
+	return true
+}
+
+func (sc StreamConsumer) claim(ctx context.Context, workers int) {
+	ticker := time.NewTicker(sc.ClaimIdle / 2)
+	defer ticker.Stop()
+
+	next := 0
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		next = sc.claimStale(ctx, workers, next)
+	}
+}
+
+// claimStale pages through every pending item and claims the stale ones, handing them to the workers in turn
+// starting with next. Returns the worker to hand the next claimed item to.
+func (sc StreamConsumer) claimStale(ctx context.Context, workers int, next int) int {
+	const pageSize = 100
+
+	staleBefore := time.Now().Add(-sc.ClaimIdle)
+	start := XStart
+
+	for ctx.Err() == nil {
+		pendingItems, err := sc.Client.xPending(sc.Key, sc.Group, start, pageSize)
+		if err != nil {
+			sc.report(err)
+			return next
+		}
+
+		for _, pendingItem := range pendingItems {
+			if pendingItem.LastDelivered.After(staleBefore) {
+				continue
+			}
+
+			claimed, err := sc.Client.XCLAIM(sc.Key, sc.Group, sc.workerName(next), staleBefore, pendingItem.ID)
+			if err != nil {
+				sc.report(err)
+				continue
+			}
+
+			if len(claimed) > 0 {
+				next = (next + 1) % workers
+			}
+		}
+
+		if len(pendingItems) < pageSize {
+			break
+		}
+
+		start = pendingItems[len(pendingItems)-1].ID.Next()
+	}
+
+	return next
+}
+
+func (sc StreamConsumer) wait(ctx context.Context) {
+	timer := time.NewTimer(sc.pollInterval())
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+func (sc StreamConsumer) report(err error) {
+	if sc.OnError != nil {
+		sc.OnError(err)
+	}
+}
@@ -0,0 +1,149 @@
+package redimo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamConsumer(t *testing.T) {
+	c := newClient(t)
+	key := "x1"
+	group := "group"
+
+	var ids []XID
+
+	for i := 0; i < 10; i++ {
+		id, err := c.XADD(key, XAutoID, map[string]Value{"i": IntValue{int64(i)}})
+		assert.NoError(t, err)
+
+		ids = append(ids, id)
+	}
+
+	assert.NoError(t, c.XGROUP(key, group, XStart))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	mutex := sync.Mutex{}
+	attempts := make(map[XID]int)
+	handled := make(map[XID]bool)
+
+	consumer := StreamConsumer{
+		Client:       c,
+		Key:          key,
+		Group:        group,
+		Consumer:     "planet",
+		Workers:      3,
+		PollInterval: 100 * time.Millisecond,
+		Handler: func(ctx context.Context, item StreamItem) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			attempts[item.ID]++
+
+			if item.Fields["i"].Int() == 3 && attempts[item.ID] == 1 {
+				return errors.New("transient failure")
+			}
+
+			handled[item.ID] = true
+
+			if len(handled) == len(ids) {
+				cancel()
+			}
+
+			return nil
+		},
+	}
+
+	done := make(chan error)
+
+	go func() {
+		done <- consumer.Run(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(30 * time.Second):
+		cancel()
+		assert.Fail(t, "stream consumer did not handle all items")
+		<-done
+	}
+
+	assert.Len(t, handled, len(ids))
+	assert.Equal(t, 2, attempts[ids[3]])
+
+	pendingItems, err := c.XPENDING(key, group, 100)
+	assert.NoError(t, err)
+	assert.Empty(t, pendingItems)
+}
+
+func TestStreamConsumerDeadLetter(t *testing.T) {
+	c := newClient(t)
+	key := "x1"
+	group := "group"
+	deadLetterKey := "x1-dead"
+
+	_, err := c.XADD(key, XAutoID, map[string]Value{"poison": StringValue{"pill"}})
+	assert.NoError(t, err)
+
+	err = StreamConsumer{Client: c, Key: key, Group: group, Handler: func(context.Context, StreamItem) error {
+		return nil
+	}}.Run(context.Background())
+	assert.Equal(t, ErrXGroupNotInitialized, err)
+
+	assert.NoError(t, c.XGROUP(key, group, XStart))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	consumer := StreamConsumer{
+		Client:       c,
+		Key:          key,
+		Group:        group,
+		Consumer:     "planet",
+		PollInterval: 100 * time.Millisecond,
+		DeadLetter:   XDeadLetterPolicy{MaxDeliveries: 3, Key: deadLetterKey},
+		Handler: func(ctx context.Context, item StreamItem) error {
+			return errors.New("poisoned")
+		},
+	}
+
+	go func() {
+		for ctx.Err() == nil {
+			count, err := c.XLEN(deadLetterKey)
+			if err == nil && count > 0 {
+				cancel()
+			}
+
+			time.Sleep(100 * time.Millisecond)
+		}
+	}()
+
+	assert.NoError(t, consumer.Run(ctx))
+
+	deadItems, err := c.XRANGE(deadLetterKey, XStart, XEnd, 10)
+	assert.NoError(t, err)
+	assert.Len(t, deadItems, 1)
+	assert.Equal(t, "pill", deadItems[0].Fields["poison"].String())
+
+	pendingItems, err := c.XPENDING(key, group, 100)
+	assert.NoError(t, err)
+	assert.Empty(t, pendingItems)
+}
+
+func TestStreamConsumerValidation(t *testing.T) {
+	handler := func(context.Context, StreamItem) error { return nil }
+
+	err := StreamConsumer{Key: "x1", Group: "group"}.Run(context.Background())
+	assert.Equal(t, ErrNoStreamHandler, err)
+
+	err = StreamConsumer{Key: "x1", Group: "group", Handler: handler, ClaimIdle: time.Millisecond}.Run(context.Background())
+	assert.Equal(t, ErrInvalidClaimIdle, err)
+
+	err = StreamConsumer{Key: "x1", Group: "group", Handler: handler, ClaimIdle: -time.Second}.Run(context.Background())
+	assert.Equal(t, ErrInvalidClaimIdle, err)
+}
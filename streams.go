@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -26,9 +27,17 @@ type XID string
 
 var ErrXGroupNotInitialized = errors.New("consumer group not initialized with XGROUP")
 
+// ErrXInvalidDeadLetterKey is returned by XGROUPSETDEADLETTER when the dead-letter stream key is empty or is the
+// stream's own key.
+var ErrXInvalidDeadLetterKey = errors.New("dead-letter stream key must be set and differ from the stream key")
+
 const consumerKey = "cnk"
 const lastDeliveryTimestampKey = "ldk"
 const deliveryCountKey = "dck"
+const consumerSeenKey = "csk"
+const streamLengthKey = "xln"
+const deadLetterMaxKey = "dlm"
+const deadLetterStreamKey = "dlk"
 
 const XStart XID = "00000000000000000000-00000000000000000000"
 const XEnd XID = "99999999999999999999-99999999999999999999"
@@ -64,6 +73,31 @@ func (xid XID) sequenceUpdateAction(key string, c Client) dynamodb.TransactWrite
 	builder := newExpresionBuilder()
 	builder.condition(fmt.Sprintf("#%v < :%v", vk, vk), vk)
 	builder.SET(fmt.Sprintf("#%v = :%v", vk, vk), vk, StringValue{xid.String()}.ToAV())
+	builder.clauses["ADD"] = append(builder.clauses["ADD"], fmt.Sprintf("#%v :delta", streamLengthKey))
+	builder.keys[streamLengthKey] = struct{}{}
+	builder.values["delta"] = IntValue{1}.ToAV()
+
+	return dynamodb.TransactWriteItem{
+		Update: &dynamodb.Update{
+			ConditionExpression:       builder.conditionExpression(),
+			ExpressionAttributeNames:  builder.expressionAttributeNames(),
+			ExpressionAttributeValues: builder.expressionAttributeValues(),
+			Key:                       xSequenceKey(key).toAV(c),
+			TableName:                 aws.String(c.table),
+			UpdateExpression:          builder.updateExpression(),
+		},
+	}
+}
+
+// xLengthDecrementAction decrements the stream length, on the condition that it's positive – streams written to
+// before the counter was introduced have items that it never counted.
+func xLengthDecrementAction(key string, c Client) dynamodb.TransactWriteItem {
+	builder := newExpresionBuilder()
+	builder.condition(fmt.Sprintf("#%v > :zero", streamLengthKey), streamLengthKey)
+	builder.values["zero"] = IntValue{0}.ToAV()
+	builder.clauses["ADD"] = append(builder.clauses["ADD"], fmt.Sprintf("#%v :delta", streamLengthKey))
+	builder.keys[streamLengthKey] = struct{}{}
+	builder.values["delta"] = IntValue{-1}.ToAV()
 
 	return dynamodb.TransactWriteItem{
 		Update: &dynamodb.Update{
@@ -244,29 +278,20 @@ func (c Client) XADD(key string, id XID, fields map[string]Value) (returnedID XI
 	retry := true
 	retryCount := 0
 
-	for retry && retryCount < 2 {
-		var actions []dynamodb.TransactWriteItem
-
-		if id == XAutoID {
-			now := time.Now()
-			newSequence, err := c.INCR(strings.Join([]string{"_redimo", "xcount", key}, "/"))
+	wrappedFields := make(map[string]ReturnValue)
 
-			if err != nil {
-				return id, err
-			}
-
-			id = NewXID(now, uint64(newSequence))
-		}
+	for k, v := range fields {
+		wrappedFields[k] = ReturnValue{v.ToAV()}
+	}
 
-		wrappedFields := make(map[string]ReturnValue)
+	for retry && retryCount < 2 {
+		var actions []dynamodb.TransactWriteItem
 
-		for k, v := range fields {
-			wrappedFields[k] = ReturnValue{v.ToAV()}
+		id, actions, err = c.xAddActions(key, id, wrappedFields)
+		if err != nil {
+			return id, err
 		}
 
-		actions = append(actions, StreamItem{ID: id, Fields: wrappedFields}.putAction(key, c))
-		actions = append(actions, id.sequenceUpdateAction(key, c))
-
 		_, err := c.ddbClient.TransactWriteItemsRequest(&dynamodb.TransactWriteItemsInput{
 			TransactItems: actions,
 		}).Send(context.TODO())
@@ -290,6 +315,26 @@ func (c Client) XADD(key string, id XID, fields map[string]Value) (returnedID XI
 	return id, err
 }
 
+func (c Client) xAddActions(key string, id XID, fields map[string]ReturnValue) (XID, []dynamodb.TransactWriteItem, error) {
+	if id == XAutoID {
+		now := time.Now()
+		newSequence, err := c.INCR(strings.Join([]string{"_redimo", "xcount", key}, "/"))
+
+		if err != nil {
+			return id, nil, err
+		}
+
+		id = NewXID(now, uint64(newSequence))
+	}
+
+	actions := []dynamodb.TransactWriteItem{
+		StreamItem{ID: id, Fields: fields}.putAction(key, c),
+		id.sequenceUpdateAction(key, c),
+	}
+
+	return id, actions, nil
+}
+
 func (c Client) xInit(key string) (err error) {
 	_, err = c.ddbClient.TransactWriteItemsRequest(&dynamodb.TransactWriteItemsInput{
 		TransactItems: []dynamodb.TransactWriteItem{c.xInitAction(key)},
@@ -318,14 +363,21 @@ func (c Client) xInitAction(key string) dynamodb.TransactWriteItem {
 	}
 }
 
+// XCLAIM transfers ownership of the given pending entries to the consumer, as long as they were
+// last delivered before the given time. The delivery count of each claimed entry is incremented,
+// so entries bouncing between consumers are still subject to the group's XDeadLetterPolicy.
+//
+// Works similar to https://redis.io/commands/xclaim
 func (c Client) XCLAIM(key string, group string, consumer string, lastDeliveredBefore time.Time, ids ...XID) (items []StreamItem, err error) {
 	for _, id := range ids {
 		builder := newExpresionBuilder()
 		builder.addConditionExists(c.pk)
 		builder.addConditionLessThanOrEqualTo(lastDeliveryTimestampKey, IntValue{lastDeliveredBefore.Unix()})
 		builder.updateSET(lastDeliveryTimestampKey, IntValue{time.Now().Unix()})
-		builder.updateSET(deliveryCountKey, IntValue{0})
 		builder.updateSET(consumerKey, StringValue{consumer})
+		builder.clauses["ADD"] = append(builder.clauses["ADD"], fmt.Sprintf("#%v :delta", deliveryCountKey))
+		builder.keys[deliveryCountKey] = struct{}{}
+		builder.values["delta"] = IntValue{1}.ToAV()
 
 		_, err = c.ddbClient.UpdateItemRequest(&dynamodb.UpdateItemInput{
 			ConditionExpression:       builder.conditionExpression(),
@@ -360,21 +412,20 @@ func (c Client) XCLAIM(key string, group string, consumer string, lastDeliveredB
 //
 // Note that this operation is not atomic across given IDs – it's possible that an error is returned
 // based on a problem deleting one of the IDs when the others have been deleted. Even when an error is returned,
-// the items that were deleted will still be populated.
+// the items that were deleted will still be populated. Each ID is deleted in a transaction that also
+// decrements the stream length returned by XLEN.
+//
+// Cost is O(1) / 4 WCUs for each ID.
 //
 // Works similar to https://redis.io/commands/xdel
 func (c Client) XDEL(key string, ids ...XID) (deletedItems []XID, err error) {
 	for _, id := range ids {
-		resp, err := c.ddbClient.DeleteItemRequest(&dynamodb.DeleteItemInput{
-			Key:          keyDef{pk: key, sk: id.String()}.toAV(c),
-			ReturnValues: dynamodb.ReturnValueAllOld,
-			TableName:    aws.String(c.table),
-		}).Send(context.TODO())
+		deleted, err := c.xDelete(key, id)
 		if err != nil {
 			return deletedItems, err
 		}
 
-		if len(resp.Attributes) > 0 {
+		if deleted {
 			deletedItems = append(deletedItems, id)
 		}
 	}
@@ -382,6 +433,57 @@ func (c Client) XDEL(key string, ids ...XID) (deletedItems []XID, err error) {
 	return
 }
 
+func (c Client) xDelete(key string, id XID) (deleted bool, err error) {
+	builder := newExpresionBuilder()
+	builder.addConditionExists(c.pk)
+
+	decrement := true
+
+	for retryCount := 0; retryCount < 5; retryCount++ {
+		actions := []dynamodb.TransactWriteItem{
+			{
+				Delete: &dynamodb.Delete{
+					ConditionExpression:      builder.conditionExpression(),
+					ExpressionAttributeNames: builder.expressionAttributeNames(),
+					Key:                      keyDef{pk: key, sk: id.String()}.toAV(c),
+					TableName:                aws.String(c.table),
+				},
+			},
+		}
+
+		if decrement {
+			actions = append(actions, xLengthDecrementAction(key, c))
+		}
+
+		_, err = c.ddbClient.TransactWriteItemsRequest(&dynamodb.TransactWriteItemsInput{
+			TransactItems: actions,
+		}).Send(context.TODO())
+		if err == nil {
+			return true, nil
+		}
+
+		if !conditionFailureError(err) {
+			return false, err
+		}
+
+		// Either the item doesn't exist, the length is already zero because the item was never counted,
+		// or the transaction conflicted with a concurrent XADD.
+		items, err := c.XRANGE(key, id, id, 1)
+		if err != nil || len(items) == 0 {
+			return false, err
+		}
+
+		length, err := c.StronglyConsistent().XLEN(key)
+		if err != nil {
+			return false, err
+		}
+
+		decrement = length > 0
+	}
+
+	return false, errors.New("too much contention")
+}
+
 // XGROUP creates a new group for the stream at the given key. Specifying the start XID
 // as XStart will cause consumers of the group to read from the beginning of the stream,
 // and any existing or generated XID can be used to denote a custom starting point.
@@ -394,6 +496,207 @@ func (c Client) XDEL(key string, ids ...XID) (deletedItems []XID, err error) {
 // Works similar to https://redis.io/commands/xgroup
 func (c Client) XGROUP(key string, group string, start XID) (err error) {
 	err = c.xGroupCursorSet(key, group, start)
+	if err == nil {
+		_, err = c.HSET(c.xGroupsIndexKey(key), map[string]Value{group: IntValue{time.Now().Unix()}})
+	}
+
+	return
+}
+
+// XGROUPDESTROY removes the consumer group from the stream at the given key, along with
+// its cursor, its pending entries and its registered consumers. Returns true if the group existed.
+//
+// The cursor is removed first, so any XREADGROUP calls made while the rest of the group
+// is being cleaned up will fail with ErrXGroupNotInitialized.
+//
+// Cost is O(N) / N WCUs where N is the number of pending entries and consumers in the group.
+//
+// Works similar to https://redis.io/commands/xgroup
+func (c Client) XGROUPDESTROY(key string, group string) (destroyed bool, err error) {
+	resp, err := c.ddbClient.DeleteItemRequest(&dynamodb.DeleteItemInput{
+		Key:          c.xGroupCursorKey(key, group).toAV(c),
+		ReturnValues: dynamodb.ReturnValueAllOld,
+		TableName:    aws.String(c.table),
+	}).Send(context.TODO())
+	if err != nil {
+		return
+	}
+
+	destroyed = len(resp.Attributes) > 0
+
+	_, err = c.HDEL(c.xGroupsIndexKey(key), group)
+	if err != nil {
+		return
+	}
+
+	_, err = c.xGroupPurge(key, group, "")
+
+	return
+}
+
+// XGROUPSETID moves the last delivered ID of the consumer group to the given XID. Unlike XREADGROUP,
+// which can only move the cursor forward, XGROUPSETID can also move it backwards to have the group
+// re-read entries. Pending entries are not modified.
+//
+// Returns ErrXGroupNotInitialized if the group does not exist.
+//
+// Cost is O(1) / 1 WCU.
+//
+// Works similar to https://redis.io/commands/xgroup
+func (c Client) XGROUPSETID(key string, group string, id XID) (err error) {
+	builder := newExpresionBuilder()
+	builder.addConditionExists(vk)
+	builder.updateSET(vk, StringValue{id.String()})
+
+	_, err = c.ddbClient.UpdateItemRequest(&dynamodb.UpdateItemInput{
+		ConditionExpression:       builder.conditionExpression(),
+		ExpressionAttributeNames:  builder.expressionAttributeNames(),
+		ExpressionAttributeValues: builder.expressionAttributeValues(),
+		Key:                       c.xGroupCursorKey(key, group).toAV(c),
+		TableName:                 aws.String(c.table),
+		UpdateExpression:          builder.updateExpression(),
+	}).Send(context.TODO())
+
+	if conditionFailureError(err) {
+		return ErrXGroupNotInitialized
+	}
+
+	return
+}
+
+// XGROUPCREATECONSUMER registers a named consumer in the group. Returns true if the
+// consumer was created, and false if it already existed.
+//
+// Returns ErrXGroupNotInitialized if the group does not exist.
+//
+// Cost is O(1) / 2 WCUs.
+//
+// Works similar to https://redis.io/commands/xgroup
+func (c Client) XGROUPCREATECONSUMER(key string, group string, consumer string) (created bool, err error) {
+	cursorCheck := newExpresionBuilder()
+	cursorCheck.addConditionExists(vk)
+
+	consumerPut := newExpresionBuilder()
+	consumerPut.addConditionNotExists(c.pk)
+	consumerPut.updateSET(consumerSeenKey, IntValue{time.Now().Unix()})
+
+	_, err = c.ddbClient.TransactWriteItemsRequest(&dynamodb.TransactWriteItemsInput{
+		TransactItems: []dynamodb.TransactWriteItem{
+			{
+				ConditionCheck: &dynamodb.ConditionCheck{
+					ConditionExpression:      cursorCheck.conditionExpression(),
+					ExpressionAttributeNames: cursorCheck.expressionAttributeNames(),
+					Key:                      c.xGroupCursorKey(key, group).toAV(c),
+					TableName:                aws.String(c.table),
+				},
+			},
+			{
+				Update: &dynamodb.Update{
+					ConditionExpression:       consumerPut.conditionExpression(),
+					ExpressionAttributeNames:  consumerPut.expressionAttributeNames(),
+					ExpressionAttributeValues: consumerPut.expressionAttributeValues(),
+					Key:                       c.xGroupConsumerKey(key, group, consumer).toAV(c),
+					TableName:                 aws.String(c.table),
+					UpdateExpression:          consumerPut.updateExpression(),
+				},
+			},
+		},
+	}).Send(context.TODO())
+
+	if conditionFailureError(err) {
+		_, err = c.xGroupCursorGet(key, group)
+		return false, err
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// XGROUPDELCONSUMER removes the named consumer from the group. Any entries pending
+// for the consumer are dropped from the group's pending entries list, so they
+// will not be delivered again – the count of dropped entries is returned.
+//
+// Cost is O(N) / N WCUs where N is the number of pending entries in the group.
+//
+// Works similar to https://redis.io/commands/xgroup
+func (c Client) XGROUPDELCONSUMER(key string, group string, consumer string) (pendingCount int64, err error) {
+	pendingCount, err = c.xGroupPurge(key, group, consumer)
+	if err != nil {
+		return
+	}
+
+	_, err = c.ddbClient.DeleteItemRequest(&dynamodb.DeleteItemInput{
+		Key:       c.xGroupConsumerKey(key, group, consumer).toAV(c),
+		TableName: aws.String(c.table),
+	}).Send(context.TODO())
+
+	return
+}
+
+// xGroupPurge deletes the pending entries of the group belonging to the given consumer
+// and returns how many were deleted. If the consumer is empty, every item in the group
+// partition is deleted, including the registered consumers.
+func (c Client) xGroupPurge(key string, group string, consumer string) (deletedPending int64, err error) {
+	hasMoreResults := true
+
+	var cursor map[string]dynamodb.AttributeValue
+
+	for hasMoreResults {
+		builder := newExpresionBuilder()
+		builder.addConditionEquality(c.pk, StringValue{c.xGroupKey(key, group)})
+
+		var filter *string
+
+		if consumer != "" {
+			builder.condition(fmt.Sprintf("#%v BETWEEN :start AND :stop", c.sk), c.sk)
+			builder.values["start"] = XStart.av()
+			builder.values["stop"] = XEnd.av()
+			builder.values[consumerKey] = StringValue{consumer}.ToAV()
+			builder.keys[consumerKey] = struct{}{}
+			filter = aws.String(fmt.Sprintf("#%v = :%v", consumerKey, consumerKey))
+		}
+
+		resp, err := c.ddbClient.QueryRequest(&dynamodb.QueryInput{
+			ConsistentRead:            aws.Bool(true),
+			ExclusiveStartKey:         cursor,
+			ExpressionAttributeNames:  builder.expressionAttributeNames(),
+			ExpressionAttributeValues: builder.expressionAttributeValues(),
+			FilterExpression:          filter,
+			KeyConditionExpression:    builder.conditionExpression(),
+			TableName:                 aws.String(c.table),
+		}).Send(context.TODO())
+
+		if err != nil {
+			return deletedPending, err
+		}
+
+		if len(resp.LastEvaluatedKey) > 0 {
+			cursor = resp.LastEvaluatedKey
+		} else {
+			hasMoreResults = false
+		}
+
+		for _, item := range resp.Items {
+			itemKey := parseKey(item, c)
+
+			deleteResp, err := c.ddbClient.DeleteItemRequest(&dynamodb.DeleteItemInput{
+				Key:          itemKey.toAV(c),
+				ReturnValues: dynamodb.ReturnValueAllOld,
+				TableName:    aws.String(c.table),
+			}).Send(context.TODO())
+			if err != nil {
+				return deletedPending, err
+			}
+
+			if len(deleteResp.Attributes) > 0 && itemKey.sk >= XStart.String() && itemKey.sk <= XEnd.String() {
+				deletedPending++
+			}
+		}
+	}
+
 	return
 }
 
@@ -405,6 +708,11 @@ func (c Client) xGroupCursorSet(key string, group string, start XID) error {
 }
 
 func (c Client) xGroupCursorGet(key string, group string) (id XID, err error) {
+	id, _, err = c.xGroupLoad(key, group)
+	return
+}
+
+func (c Client) xGroupLoad(key string, group string) (id XID, policy XDeadLetterPolicy, err error) {
 	resp, err := c.ddbClient.GetItemRequest(&dynamodb.GetItemInput{
 		ConsistentRead: aws.Bool(true),
 		Key:            c.xGroupCursorKey(key, group).toAV(c),
@@ -416,27 +724,268 @@ func (c Client) xGroupCursorGet(key string, group string) (id XID, err error) {
 
 	cursor := aws.StringValue(resp.Item[vk].S)
 	if cursor == "" {
-		return id, ErrXGroupNotInitialized
+		return id, policy, ErrXGroupNotInitialized
 	}
 
-	return XID(cursor), nil
+	policy.MaxDeliveries = ReturnValue{resp.Item[deadLetterMaxKey]}.Int()
+	policy.Key = aws.StringValue(resp.Item[deadLetterStreamKey].S)
+
+	return XID(cursor), policy, nil
 }
 
 func (c Client) xGroupCursorKey(key string, group string) keyDef {
 	return keyDef{pk: c.xGroupKey(key, group), sk: "_redimo/cursor"}
 }
 
+func (c Client) xGroupConsumerKey(key string, group string, consumer string) keyDef {
+	return keyDef{pk: c.xGroupKey(key, group), sk: "_redimo/consumer/" + consumer}
+}
+
+func (c Client) xGroupsIndexKey(key string) string {
+	return strings.Join([]string{"_redimo", "xgroups", key}, "/")
+}
+
+// xGroupConsumerTouchAction records that entries were just delivered to the consumer, registering it if needed.
+func (c Client) xGroupConsumerTouchAction(key string, group string, consumer string) dynamodb.TransactWriteItem {
+	builder := newExpresionBuilder()
+	builder.updateSET(consumerSeenKey, IntValue{time.Now().Unix()})
+
+	return dynamodb.TransactWriteItem{
+		Update: &dynamodb.Update{
+			ExpressionAttributeNames:  builder.expressionAttributeNames(),
+			ExpressionAttributeValues: builder.expressionAttributeValues(),
+			Key:                       c.xGroupConsumerKey(key, group, consumer).toAV(c),
+			TableName:                 aws.String(c.table),
+			UpdateExpression:          builder.updateExpression(),
+		},
+	}
+}
+
+func (c Client) xGroupConsumerTouch(key string, group string, consumer string) error {
+	touch := c.xGroupConsumerTouchAction(key, group, consumer).Update
+
+	_, err := c.ddbClient.UpdateItemRequest(&dynamodb.UpdateItemInput{
+		ExpressionAttributeNames:  touch.ExpressionAttributeNames,
+		ExpressionAttributeValues: touch.ExpressionAttributeValues,
+		Key:                       touch.Key,
+		TableName:                 touch.TableName,
+		UpdateExpression:          touch.UpdateExpression,
+	}).Send(context.TODO())
+
+	return err
+}
+
 func (c Client) xGroupKey(key string, group string) string {
 	return strings.Join([]string{"_redimo", key, group}, "/")
 }
 
-// XLEN counts the number of items in the stream with XIDs between the given XIDs. To count
-// the entire stream, pass XStart and XEnd as the start and end XIDs.
+// XStreamInfo holds the general information about a stream, as returned by XINFOSTREAM.
+type XStreamInfo struct {
+	Length          int64
+	Groups          int64
+	LastGeneratedID XID
+	FirstEntry      StreamItem
+	LastEntry       StreamItem
+}
+
+// XGroupInfo holds the state of a consumer group, as returned by XINFOGROUPS.
+type XGroupInfo struct {
+	Name            string
+	Consumers       int64
+	Pending         int64
+	LastDeliveredID XID
+}
+
+// XConsumerInfo holds the state of a consumer in a group, as returned by XINFOCONSUMERS.
+// Idle is the time elapsed since XREADGROUP last delivered entries to the consumer, accurate to one second.
+type XConsumerInfo struct {
+	Name    string
+	Pending int64
+	Idle    time.Duration
+}
+
+// XINFOSTREAM returns the length of the stream, the number of consumer groups, the first
+// and last entries and the last generated ID. The entries will be zero-valued if the stream is empty.
 //
-// Cost is O(N) or ~N RCUs where N is the number / size of items counted.
+// Cost is O(N) / 4 RCUs + ~N RCUs to count the groups, where N is the number of consumer groups.
+//
+// Works similar to https://redis.io/commands/xinfo-stream
+func (c Client) XINFOSTREAM(key string) (info XStreamInfo, err error) {
+	info.Length, err = c.XLEN(key)
+	if err != nil {
+		return
+	}
+
+	info.Groups, err = c.HLEN(c.xGroupsIndexKey(key))
+	if err != nil {
+		return
+	}
+
+	resp, err := c.ddbClient.GetItemRequest(&dynamodb.GetItemInput{
+		ConsistentRead: aws.Bool(c.consistentReads),
+		Key:            xSequenceKey(key).toAV(c),
+		TableName:      aws.String(c.table),
+	}).Send(context.TODO())
+	if err != nil {
+		return
+	}
+
+	info.LastGeneratedID = XID(aws.StringValue(resp.Item[vk].S))
+
+	first, err := c.XRANGE(key, XStart, XEnd, 1)
+	if err != nil || len(first) == 0 {
+		return
+	}
+
+	info.FirstEntry = first[0]
+
+	last, err := c.XREVRANGE(key, XEnd, XStart, 1)
+	if err != nil || len(last) == 0 {
+		return
+	}
+
+	info.LastEntry = last[0]
+
+	return
+}
+
+// XINFOGROUPS returns the state of every consumer group created on the stream with XGROUP.
+//
+// Cost is O(N) or ~N RCUs where N is the total number of pending entries and consumers across all groups.
+//
+// Works similar to https://redis.io/commands/xinfo-groups
+func (c Client) XINFOGROUPS(key string) (groups []XGroupInfo, err error) {
+	groupNames, err := c.HKEYS(c.xGroupsIndexKey(key))
+	if err != nil {
+		return
+	}
+
+	for _, name := range groupNames {
+		group := XGroupInfo{Name: name}
+
+		group.LastDeliveredID, err = c.xGroupCursorGet(key, name)
+		if err != nil {
+			return groups, err
+		}
+
+		consumers, err := c.XINFOCONSUMERS(key, name)
+		if err != nil {
+			return groups, err
+		}
+
+		group.Consumers = int64(len(consumers))
+
+		for _, consumer := range consumers {
+			group.Pending += consumer.Pending
+		}
+
+		groups = append(groups, group)
+	}
+
+	return
+}
+
+// XINFOCONSUMERS returns the consumers of the group with their pending entry counts and idle times.
+// Consumers are registered either with XGROUPCREATECONSUMER or the first time XREADGROUP delivers entries to them.
+//
+// Cost is O(N) or ~N RCUs where N is the number of pending entries and consumers in the group.
+//
+// Works similar to https://redis.io/commands/xinfo-consumers
+func (c Client) XINFOCONSUMERS(key string, group string) (consumers []XConsumerInfo, err error) {
+	pendingCounts := make(map[string]int64)
+	lastSeen := make(map[string]time.Time)
+	consumerPrefix := c.xGroupConsumerKey(key, group, "").sk
+	hasMoreResults := true
+
+	var cursor map[string]dynamodb.AttributeValue
+
+	for hasMoreResults {
+		builder := newExpresionBuilder()
+		builder.addConditionEquality(c.pk, StringValue{c.xGroupKey(key, group)})
+
+		resp, err := c.ddbClient.QueryRequest(&dynamodb.QueryInput{
+			ConsistentRead:            aws.Bool(c.consistentReads),
+			ExclusiveStartKey:         cursor,
+			ExpressionAttributeNames:  builder.expressionAttributeNames(),
+			ExpressionAttributeValues: builder.expressionAttributeValues(),
+			KeyConditionExpression:    builder.conditionExpression(),
+			TableName:                 aws.String(c.table),
+		}).Send(context.TODO())
+
+		if err != nil {
+			return consumers, err
+		}
+
+		if len(resp.LastEvaluatedKey) > 0 {
+			cursor = resp.LastEvaluatedKey
+		} else {
+			hasMoreResults = false
+		}
+
+		for _, item := range resp.Items {
+			sk := parseKey(item, c).sk
+
+			switch {
+			case strings.HasPrefix(sk, consumerPrefix):
+				timestamp, _ := strconv.ParseInt(aws.StringValue(item[consumerSeenKey].N), 10, 64)
+				lastSeen[strings.TrimPrefix(sk, consumerPrefix)] = time.Unix(timestamp, 0)
+			case sk >= XStart.String() && sk <= XEnd.String():
+				pendingCounts[parsePendingItem(item, c).Consumer]++
+			}
+		}
+	}
+
+	for name := range pendingCounts {
+		if _, ok := lastSeen[name]; !ok {
+			lastSeen[name] = time.Time{}
+		}
+	}
+
+	for name, seen := range lastSeen {
+		consumer := XConsumerInfo{Name: name, Pending: pendingCounts[name]}
+		if !seen.IsZero() {
+			consumer.Idle = time.Since(seen)
+		}
+
+		consumers = append(consumers, consumer)
+	}
+
+	sort.Slice(consumers, func(i, j int) bool {
+		return consumers[i].Name < consumers[j].Name
+	})
+
+	return
+}
+
+// XLEN returns the number of items in the stream. The length is a counter maintained
+// transactionally by XADD, XDEL and XTRIM, so reading it does not depend on the size of the stream.
+// To count the items between two XIDs, use XCOUNT.
+//
+// Streams that were written to before the counter was introduced will not report their
+// older items – use XCOUNT(key, XStart, XEnd) to count them. Deleting those items doesn't
+// take the length below zero.
+//
+// Cost is O(1) / 1 RCU.
 //
 // Works similar to https://redis.io/commands/xlen
-func (c Client) XLEN(key string, start, stop XID) (count int64, err error) {
+func (c Client) XLEN(key string) (count int64, err error) {
+	resp, err := c.ddbClient.GetItemRequest(&dynamodb.GetItemInput{
+		ConsistentRead: aws.Bool(c.consistentReads),
+		Key:            xSequenceKey(key).toAV(c),
+		TableName:      aws.String(c.table),
+	}).Send(context.TODO())
+	if err == nil {
+		count = ReturnValue{resp.Item[streamLengthKey]}.Int()
+	}
+
+	return
+}
+
+// XCOUNT counts the number of items in the stream with XIDs between the given XIDs. To count
+// the entire stream, pass XStart and XEnd as the start and end XIDs, or use XLEN.
+//
+// Cost is O(N) or ~N RCUs where N is the number / size of items counted.
+func (c Client) XCOUNT(key string, start, stop XID) (count int64, err error) {
 	hasMoreResults := true
 
 	var cursor map[string]dynamodb.AttributeValue
@@ -475,6 +1024,11 @@ func (c Client) XLEN(key string, start, stop XID) (count int64, err error) {
 }
 
 func (c Client) XPENDING(key string, group string, count int64) (pendingItems []PendingItem, err error) {
+	return c.xPending(key, group, XStart, count)
+}
+
+// xPending returns up to count pending items with IDs from start onwards, so that the pending list can be paged.
+func (c Client) xPending(key string, group string, start XID, count int64) (pendingItems []PendingItem, err error) {
 	hasMoreResults := true
 
 	var cursor map[string]dynamodb.AttributeValue
@@ -483,7 +1037,7 @@ func (c Client) XPENDING(key string, group string, count int64) (pendingItems []
 		builder := newExpresionBuilder()
 		builder.addConditionEquality(c.pk, StringValue{c.xGroupKey(key, group)})
 		builder.condition(fmt.Sprintf("#%v BETWEEN :start AND :stop", c.sk), c.sk)
-		builder.values["start"] = XStart.av()
+		builder.values["start"] = start.av()
 		builder.values["stop"] = XEnd.av()
 
 		resp, err := c.ddbClient.QueryRequest(&dynamodb.QueryInput{
@@ -630,7 +1184,136 @@ const (
 	XReadNewAutoACK XReadOption = "READ_NEW_NO_ACK"
 )
 
-func (c Client) xGroupReadPending(key string, group string, consumer string, count int64) (items []StreamItem, err error) {
+// XDeadLetterPolicy describes when pending entries of a consumer group are given up on. Once an entry
+// has been delivered MaxDeliveries times without being acknowledged, the next attempt to read it with
+// XREADGROUP using XReadPending will instead add it to the stream at Key and acknowledge it in the group.
+// A MaxDeliveries of zero disables dead-lettering.
+type XDeadLetterPolicy struct {
+	MaxDeliveries int64
+	Key           string
+}
+
+func (p XDeadLetterPolicy) exceededBy(pi PendingItem) bool {
+	return p.MaxDeliveries > 0 && pi.DeliveryCount >= p.MaxDeliveries
+}
+
+// XGROUPSETDEADLETTER sets the dead-letter policy for the consumer group. Entries that exceed the
+// policy's MaxDeliveries are moved to the dead-letter stream with a new XID, keeping their fields,
+// and are removed from the group's pending entries in the same transaction. Passing a zero-valued policy
+// disables dead-lettering for the group.
+//
+// Returns ErrXGroupNotInitialized if the group does not exist, and ErrXInvalidDeadLetterKey if the policy
+// enables dead-lettering without a Key, or with the stream's own key.
+//
+// Cost is O(1) / 1 WCU.
+func (c Client) XGROUPSETDEADLETTER(key string, group string, policy XDeadLetterPolicy) (err error) {
+	if policy.MaxDeliveries > 0 && (policy.Key == "" || policy.Key == key) {
+		return ErrXInvalidDeadLetterKey
+	}
+
+	builder := newExpresionBuilder()
+	builder.addConditionExists(vk)
+
+	if policy.MaxDeliveries > 0 {
+		builder.updateSET(deadLetterMaxKey, IntValue{policy.MaxDeliveries})
+		builder.updateSET(deadLetterStreamKey, StringValue{policy.Key})
+	} else {
+		builder.clauses["REMOVE"] = append(builder.clauses["REMOVE"], "#"+deadLetterMaxKey, "#"+deadLetterStreamKey)
+		builder.keys[deadLetterMaxKey] = struct{}{}
+		builder.keys[deadLetterStreamKey] = struct{}{}
+	}
+
+	_, err = c.ddbClient.UpdateItemRequest(&dynamodb.UpdateItemInput{
+		ConditionExpression:       builder.conditionExpression(),
+		ExpressionAttributeNames:  builder.expressionAttributeNames(),
+		ExpressionAttributeValues: builder.expressionAttributeValues(),
+		Key:                       c.xGroupCursorKey(key, group).toAV(c),
+		TableName:                 aws.String(c.table),
+		UpdateExpression:          builder.updateExpression(),
+	}).Send(context.TODO())
+
+	if conditionFailureError(err) {
+		return ErrXGroupNotInitialized
+	}
+
+	return
+}
+
+// xDeadLetter moves the pending item to the dead-letter stream and acknowledges it, as long as
+// the pending entry hasn't been delivered or claimed again since it was read.
+func (c Client) xDeadLetter(key string, group string, pi PendingItem, policy XDeadLetterPolicy) error {
+	ackBuilder := newExpresionBuilder()
+	ackBuilder.addConditionEquality(consumerKey, StringValue{pi.Consumer})
+	ackBuilder.addConditionEquality(deliveryCountKey, IntValue{pi.DeliveryCount})
+
+	ackAction := dynamodb.TransactWriteItem{
+		Delete: &dynamodb.Delete{
+			ConditionExpression:       ackBuilder.conditionExpression(),
+			ExpressionAttributeNames:  ackBuilder.expressionAttributeNames(),
+			ExpressionAttributeValues: ackBuilder.expressionAttributeValues(),
+			Key:                       keyDef{pk: c.xGroupKey(key, group), sk: pi.ID.String()}.toAV(c),
+			TableName:                 aws.String(c.table),
+		},
+	}
+
+	fetchedItems, err := c.XRANGE(key, pi.ID, pi.ID, 1)
+	if err != nil {
+		return err
+	}
+
+	for retryCount := 0; retryCount < 5; retryCount++ {
+		actions := []dynamodb.TransactWriteItem{ackAction}
+
+		if len(fetchedItems) > 0 {
+			_, addActions, err := c.xAddActions(policy.Key, XAutoID, fetchedItems[0].Fields)
+			if err != nil {
+				return err
+			}
+
+			actions = append(actions, addActions...)
+		}
+
+		_, err = c.ddbClient.TransactWriteItemsRequest(&dynamodb.TransactWriteItemsInput{
+			TransactItems: actions,
+		}).Send(context.TODO())
+		if !conditionFailureError(err) {
+			return err
+		}
+
+		// If the entry was re-delivered or claimed concurrently it's no longer ours to move. Otherwise it was the
+		// dead-letter stream that failed its condition – it may not have been initialized yet, or another item
+		// took the sequence – so the move is retried.
+		unchanged, err := c.xPendingUnchanged(key, group, pi)
+		if err != nil || !unchanged {
+			return err
+		}
+
+		if err = c.xInit(policy.Key); err != nil {
+			return err
+		}
+	}
+
+	return errors.New("too much contention")
+}
+
+// xPendingUnchanged checks that the pending entry is still there, with the consumer and delivery count it
+// was read with.
+func (c Client) xPendingUnchanged(key string, group string, pi PendingItem) (bool, error) {
+	resp, err := c.ddbClient.GetItemRequest(&dynamodb.GetItemInput{
+		ConsistentRead: aws.Bool(true),
+		Key:            keyDef{pk: c.xGroupKey(key, group), sk: pi.ID.String()}.toAV(c),
+		TableName:      aws.String(c.table),
+	}).Send(context.TODO())
+	if err != nil || len(resp.Item) == 0 {
+		return false, err
+	}
+
+	current := parsePendingItem(resp.Item, c)
+
+	return current.Consumer == pi.Consumer && current.DeliveryCount == pi.DeliveryCount, nil
+}
+
+func (c Client) xGroupReadPending(key string, group string, consumer string, count int64, policy XDeadLetterPolicy) (items []StreamItem, err error) {
 	hasMoreResults := true
 
 	var cursor map[string]dynamodb.AttributeValue
@@ -668,6 +1351,15 @@ func (c Client) xGroupReadPending(key string, group string, consumer string, cou
 		for _, item := range resp.Items {
 			pendingItem := parsePendingItem(item, c)
 
+			if policy.exceededBy(pendingItem) {
+				err = c.xDeadLetter(key, group, pendingItem, policy)
+				if err != nil {
+					return items, err
+				}
+
+				continue
+			}
+
 			_, err = c.ddbClient.UpdateItemRequest(pendingItem.updateDeliveryAction(c.xGroupKey(key, group), c)).Send(context.TODO())
 			if err != nil {
 				return items, err
@@ -683,20 +1375,32 @@ func (c Client) xGroupReadPending(key string, group string, consumer string, cou
 		}
 	}
 
+	if len(items) > 0 {
+		err = c.xGroupConsumerTouch(key, group, consumer)
+	}
+
 	return
 }
 
 func (c Client) XREADGROUP(key string, group string, consumer string, option XReadOption, maxCount int64) (items []StreamItem, err error) {
+	currentCursor, policy, err := c.xGroupLoad(key, group)
+	if err != nil {
+		return
+	}
+
 	if option == XReadPending {
-		return c.xGroupReadPending(key, group, consumer, maxCount)
+		return c.xGroupReadPending(key, group, consumer, maxCount, policy)
 	}
 
 	retryCount := 0
 
 	for retryCount < 5 {
-		currentCursor, err := c.xGroupCursorGet(key, group)
-		if err != nil {
-			return items, err
+		if retryCount > 0 {
+			// Another consumer moved the cursor since it was loaded.
+			currentCursor, err = c.xGroupCursorGet(key, group)
+			if err != nil {
+				return items, err
+			}
 		}
 
 		items, err := c.XRANGE(key, currentCursor.Next(), XEnd, 1)
@@ -709,6 +1413,7 @@ func (c Client) XREADGROUP(key string, group string, consumer string, option XRe
 
 		var actions []dynamodb.TransactWriteItem
 		actions = append(actions, c.xGroupCursorPushAction(key, group, item.ID))
+		actions = append(actions, c.xGroupConsumerTouchAction(key, group, consumer))
 
 		if option == XReadNew {
 			actions = append(actions, PendingItem{
@@ -1,10 +1,13 @@
 package redimo
 
 import (
+	"context"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -20,7 +23,7 @@ func TestStreamCRU(t *testing.T) {
 	assert.Equal(t, 1, len(items))
 	assert.Equal(t, insertID1, items[0].ID)
 
-	count, err := c.XLEN("x1", XStart, XEnd)
+	count, err := c.XLEN("x1")
 	assert.NoError(t, err)
 	assert.Equal(t, int64(1), count)
 
@@ -28,10 +31,18 @@ func TestStreamCRU(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Greater(t, insertID2.String(), insertID1.String())
 
-	count, err = c.XLEN("x1", XStart, XEnd)
+	count, err = c.XLEN("x1")
 	assert.NoError(t, err)
 	assert.Equal(t, int64(2), count)
 
+	count, err = c.XCOUNT("x1", XStart, XEnd)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	count, err = c.XCOUNT("x1", insertID2, XEnd)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
 	items, err = c.XRANGE("x1", XStart, XEnd, 100)
 	assert.NoError(t, err)
 	assert.Equal(t, 2, len(items))
@@ -83,6 +94,10 @@ func TestStreamDeletes(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 2, len(deletedIds))
 
+	count, err := c.XLEN("x1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
 	items, err = c.XRANGE("x1", XStart, XEnd, 100)
 	assert.NoError(t, err)
 	assert.Equal(t, 2, len(items))
@@ -100,6 +115,10 @@ func TestStreamDeletes(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, int64(1), deletedCount)
 
+	count, err = c.XLEN("x1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
 	items, err = c.XRANGE("x1", XStart, XEnd, 100)
 	assert.NoError(t, err)
 	assert.Equal(t, 2, len(items))
@@ -107,6 +126,39 @@ func TestStreamDeletes(t *testing.T) {
 	assert.Equal(t, insertID5, items[1].ID)
 }
 
+func TestStreamDeletesUncounted(t *testing.T) {
+	c := newClient(t)
+
+	insertID1, err := c.XADD("x1", XAutoID, map[string]Value{"f1": StringValue{"v1"}})
+	assert.NoError(t, err)
+	insertID2, err := c.XADD("x1", XAutoID, map[string]Value{"f2": StringValue{"v2"}})
+	assert.NoError(t, err)
+
+	// Streams written before the length counter existed have no count for their items.
+	_, err = c.ddbClient.UpdateItemRequest(&dynamodb.UpdateItemInput{
+		ExpressionAttributeNames: map[string]string{"#len": streamLengthKey},
+		Key:                      xSequenceKey("x1").toAV(c),
+		TableName:                aws.String(c.table),
+		UpdateExpression:         aws.String("REMOVE #len"),
+	}).Send(context.TODO())
+	assert.NoError(t, err)
+
+	insertID3, err := c.XADD("x1", XAutoID, map[string]Value{"f3": StringValue{"v3"}})
+	assert.NoError(t, err)
+
+	deletedIds, err := c.XDEL("x1", insertID1, insertID2, insertID3)
+	assert.NoError(t, err)
+	assert.Equal(t, []XID{insertID1, insertID2, insertID3}, deletedIds)
+
+	count, err := c.XLEN("x1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	items, err := c.XRANGE("x1", XStart, XEnd, 100)
+	assert.NoError(t, err)
+	assert.Empty(t, items)
+}
+
 func TestStreamsConsumerGroupsNoACK(t *testing.T) {
 	c := newClient(t)
 	allItems := make([]StreamItem, 0, 25)
@@ -259,6 +311,7 @@ func TestStreamsConsumerGroupACK(t *testing.T) {
 	assert.Equal(t, 1, len(pendingItems))
 	assert.Equal(t, pendingItems[0].ID, item3[0].ID)
 	assert.Equal(t, consumer1, pendingItems[0].Consumer)
+	assert.Equal(t, int64(2), pendingItems[0].DeliveryCount)
 
 	claimedItems, err = c.XCLAIM(key, group, consumer2, pendingItems[0].LastDelivered.Add(-10*time.Second), pendingItems[0].ID)
 	assert.NoError(t, err)
@@ -316,3 +369,204 @@ func TestRanges(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, allItems[20:25], reverse(rr2))
 }
+
+func TestStreamsConsumerGroupManagement(t *testing.T) {
+	c := newClient(t)
+	key := "x1"
+	group := "group"
+
+	var ids []XID
+
+	for i := 0; i < 5; i++ {
+		id, err := c.XADD(key, XAutoID, map[string]Value{"i": IntValue{int64(i)}})
+		assert.NoError(t, err)
+
+		ids = append(ids, id)
+	}
+
+	_, err := c.XGROUPCREATECONSUMER(key, group, "mercury")
+	assert.Equal(t, ErrXGroupNotInitialized, err)
+
+	err = c.XGROUPSETID(key, group, ids[0])
+	assert.Equal(t, ErrXGroupNotInitialized, err)
+
+	err = c.XGROUP(key, group, XStart)
+	assert.NoError(t, err)
+
+	created, err := c.XGROUPCREATECONSUMER(key, group, "mercury")
+	assert.NoError(t, err)
+	assert.True(t, created)
+
+	created, err = c.XGROUPCREATECONSUMER(key, group, "mercury")
+	assert.NoError(t, err)
+	assert.False(t, created)
+
+	for _, consumer := range []string{"mercury", "mercury", "venus"} {
+		_, err = c.XREADGROUP(key, group, consumer, XReadNew, 1)
+		assert.NoError(t, err)
+	}
+
+	pendingItems, err := c.XPENDING(key, group, 100)
+	assert.NoError(t, err)
+	assert.Len(t, pendingItems, 3)
+
+	droppedCount, err := c.XGROUPDELCONSUMER(key, group, "mercury")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), droppedCount)
+
+	pendingItems, err = c.XPENDING(key, group, 100)
+	assert.NoError(t, err)
+	assert.Len(t, pendingItems, 1)
+	assert.Equal(t, "venus", pendingItems[0].Consumer)
+
+	err = c.XGROUPSETID(key, group, ids[0])
+	assert.NoError(t, err)
+
+	items, err := c.XREADGROUP(key, group, "earth", XReadNewAutoACK, 1)
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+	assert.Equal(t, ids[1], items[0].ID)
+
+	destroyed, err := c.XGROUPDESTROY(key, group)
+	assert.NoError(t, err)
+	assert.True(t, destroyed)
+
+	destroyed, err = c.XGROUPDESTROY(key, group)
+	assert.NoError(t, err)
+	assert.False(t, destroyed)
+
+	pendingItems, err = c.XPENDING(key, group, 100)
+	assert.NoError(t, err)
+	assert.Empty(t, pendingItems)
+
+	_, err = c.XREADGROUP(key, group, "earth", XReadNew, 1)
+	assert.Equal(t, ErrXGroupNotInitialized, err)
+}
+
+func TestStreamsInfo(t *testing.T) {
+	c := newClient(t)
+	key := "x1"
+
+	info, err := c.XINFOSTREAM(key)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), info.Length)
+
+	var ids []XID
+
+	for i := 0; i < 5; i++ {
+		id, err := c.XADD(key, XAutoID, map[string]Value{"i": IntValue{int64(i)}})
+		assert.NoError(t, err)
+
+		ids = append(ids, id)
+	}
+
+	assert.NoError(t, c.XGROUP(key, "g1", XStart))
+	assert.NoError(t, c.XGROUP(key, "g2", ids[3]))
+
+	info, err = c.XINFOSTREAM(key)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), info.Length)
+	assert.Equal(t, int64(2), info.Groups)
+	assert.Equal(t, ids[4], info.LastGeneratedID)
+	assert.Equal(t, ids[0], info.FirstEntry.ID)
+	assert.Equal(t, ids[4], info.LastEntry.ID)
+
+	_, err = c.XREADGROUP(key, "g1", "mercury", XReadNew, 1)
+	assert.NoError(t, err)
+	_, err = c.XREADGROUP(key, "g1", "mercury", XReadNew, 1)
+	assert.NoError(t, err)
+	_, err = c.XREADGROUP(key, "g1", "venus", XReadNew, 1)
+	assert.NoError(t, err)
+	_, err = c.XGROUPCREATECONSUMER(key, "g1", "earth")
+	assert.NoError(t, err)
+
+	groups, err := c.XINFOGROUPS(key)
+	assert.NoError(t, err)
+	assert.Len(t, groups, 2)
+	assert.Equal(t, XGroupInfo{Name: "g1", Consumers: 3, Pending: 3, LastDeliveredID: ids[2]}, groups[0])
+	assert.Equal(t, XGroupInfo{Name: "g2", Consumers: 0, Pending: 0, LastDeliveredID: ids[3]}, groups[1])
+
+	consumers, err := c.XINFOCONSUMERS(key, "g1")
+	assert.NoError(t, err)
+	assert.Len(t, consumers, 3)
+	assert.Equal(t, "earth", consumers[0].Name)
+	assert.Equal(t, int64(0), consumers[0].Pending)
+	assert.Equal(t, "mercury", consumers[1].Name)
+	assert.Equal(t, int64(2), consumers[1].Pending)
+	assert.Equal(t, "venus", consumers[2].Name)
+	assert.Equal(t, int64(1), consumers[2].Pending)
+	assert.Less(t, consumers[1].Idle.Seconds(), float64(10))
+
+	_, err = c.XGROUPDESTROY(key, "g2")
+	assert.NoError(t, err)
+
+	groups, err = c.XINFOGROUPS(key)
+	assert.NoError(t, err)
+	assert.Len(t, groups, 1)
+	assert.Equal(t, "g1", groups[0].Name)
+}
+
+func TestStreamsDeadLetter(t *testing.T) {
+	c := newClient(t)
+	key := "x1"
+	group := "group"
+	deadLetterKey := "x1-dead"
+
+	id, err := c.XADD(key, XAutoID, map[string]Value{"poison": StringValue{"pill"}})
+	assert.NoError(t, err)
+
+	err = c.XGROUPSETDEADLETTER(key, group, XDeadLetterPolicy{MaxDeliveries: 2, Key: deadLetterKey})
+	assert.Equal(t, ErrXGroupNotInitialized, err)
+
+	assert.NoError(t, c.XGROUP(key, group, XStart))
+	assert.Equal(t, ErrXInvalidDeadLetterKey, c.XGROUPSETDEADLETTER(key, group, XDeadLetterPolicy{MaxDeliveries: 2}))
+	assert.Equal(t, ErrXInvalidDeadLetterKey, c.XGROUPSETDEADLETTER(key, group, XDeadLetterPolicy{MaxDeliveries: 2, Key: key}))
+	assert.NoError(t, c.XGROUPSETDEADLETTER(key, group, XDeadLetterPolicy{MaxDeliveries: 2, Key: deadLetterKey}))
+
+	items, err := c.XREADGROUP(key, group, "mercury", XReadNew, 1)
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+	assert.Equal(t, id, items[0].ID)
+
+	items, err = c.XREADGROUP(key, group, "mercury", XReadPending, 1)
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+
+	pendingItems, err := c.XPENDING(key, group, 10)
+	assert.NoError(t, err)
+	assert.Len(t, pendingItems, 1)
+	assert.Equal(t, int64(2), pendingItems[0].DeliveryCount)
+
+	items, err = c.XREADGROUP(key, group, "mercury", XReadPending, 1)
+	assert.NoError(t, err)
+	assert.Empty(t, items)
+
+	pendingItems, err = c.XPENDING(key, group, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, pendingItems)
+
+	deadItems, err := c.XRANGE(deadLetterKey, XStart, XEnd, 10)
+	assert.NoError(t, err)
+	assert.Len(t, deadItems, 1)
+	assert.Equal(t, "pill", deadItems[0].Fields["poison"].String())
+
+	deadCount, err := c.XLEN(deadLetterKey)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deadCount)
+
+	originalItems, err := c.XRANGE(key, XStart, XEnd, 10)
+	assert.NoError(t, err)
+	assert.Len(t, originalItems, 1)
+
+	assert.NoError(t, c.XGROUPSETDEADLETTER(key, group, XDeadLetterPolicy{}))
+	assert.NoError(t, c.XGROUPSETID(key, group, XStart))
+
+	_, err = c.XREADGROUP(key, group, "mercury", XReadNew, 1)
+	assert.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		items, err = c.XREADGROUP(key, group, "mercury", XReadPending, 1)
+		assert.NoError(t, err)
+		assert.Len(t, items, 1)
+	}
+}
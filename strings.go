@@ -2,6 +2,10 @@ package redimo
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -106,12 +110,24 @@ func (c Client) GETSET(key string, value Value) (oldValue ReturnValue, err error
 	return
 }
 
-// MGET fetches the given keys atomically in a transaction. The call is limited to 25 keys and 4MB.
+// ErrTooManyKeys is returned by MGET, MSET and MSETNX when there are more keys than fit in a single transaction.
+// Use MGETBATCH and MSETBATCH instead if the keys don't need to be read or written atomically.
+var ErrTooManyKeys = errors.New("too many keys for a single transaction")
+
+// MGET fetches the given keys atomically in a transaction, returning their values in the same order as the keys,
+// with Empty values for the keys that don't exist. The call is limited to 100 keys and 4MB.
 // See https://docs.aws.amazon.com/amazondynamodb/latest/APIReference/API_TransactGetItems.html
 //
 // Works similar to https://redis.io/commands/mget
-func (c Client) MGET(keys ...string) (values map[string]ReturnValue, err error) {
-	values = make(map[string]ReturnValue)
+func (c Client) MGET(keys ...string) (values []ReturnValue, err error) {
+	if len(keys) > maxTransactionItems {
+		return nil, ErrTooManyKeys
+	}
+
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
 	inputRequests := make([]dynamodb.TransactGetItem, len(keys))
 
 	for i, key := range keys {
@@ -135,15 +151,70 @@ func (c Client) MGET(keys ...string) (values map[string]ReturnValue, err error)
 		return
 	}
 
-	for _, item := range resp.Responses {
-		pi := parseItem(item.Item, c)
-		values[pi.pk] = pi.val
+	// The responses are in the same order as the requests.
+	values = make([]ReturnValue, len(keys))
+	for i, item := range resp.Responses {
+		values[i] = parseItem(item.Item, c).val
+	}
+
+	return
+}
+
+// MGETBATCH fetches any number of keys with BatchGetItem, 100 at a time, returning their values in the same order
+// as the keys, with Empty values for the keys that don't exist. Unlike MGET the keys are not read atomically, so
+// a concurrent MSET may be seen partially applied. Reads are strongly consistent unless the client is
+// EventuallyConsistent.
+//
+// Cost is O(N) / 1 RCU per 4KB of each value, halved with eventually consistent reads.
+//
+// Works similar to https://redis.io/commands/mget
+func (c Client) MGETBATCH(keys ...string) (values []ReturnValue, err error) {
+	keyDefs := make([]keyDef, len(keys))
+	for i, key := range keys {
+		keyDefs[i] = keyDef{pk: key, sk: emptySK}
+	}
+
+	items, err := c.batchGet(keyDefs)
+	if err != nil {
+		return
+	}
+
+	found := make(map[string]ReturnValue, len(items))
+
+	for _, item := range items {
+		pi := parseItem(item, c)
+		found[pi.pk] = pi.val
+	}
+
+	values = make([]ReturnValue, len(keys))
+	for i, key := range keys {
+		values[i] = found[key]
 	}
 
 	return
 }
 
-// MSET sets the given keys and values atomically in a transaction. The call is limited to 25 keys and 4MB.
+// MSETBATCH sets any number of keys with BatchWriteItem, 25 at a time, retrying the writes DynamoDB leaves
+// unprocessed. Unlike MSET the keys are not written atomically – if an error is returned some of them may have
+// been set.
+//
+// Cost is O(N) / 1 WCU per 1KB of each value.
+//
+// Works similar to https://redis.io/commands/mset
+func (c Client) MSETBATCH(data map[string]Value) (err error) {
+	requests := make([]dynamodb.WriteRequest, 0, len(data))
+
+	for k, v := range data {
+		item := keyDef{pk: k, sk: emptySK}.toAV(c)
+		item[vk] = v.ToAV()
+
+		requests = append(requests, dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: item}})
+	}
+
+	return c.batchWrite(requests)
+}
+
+// MSET sets the given keys and values atomically in a transaction. The call is limited to 100 keys and 4MB.
 // See https://docs.aws.amazon.com/amazondynamodb/latest/APIReference/API_TransactWriteItems.html
 //
 // Works similar to https://redis.io/commands/mset
@@ -154,6 +225,7 @@ func (c Client) MSET(data map[string]Value) (err error) {
 
 // MSETNX sets the given keys and values atomically in a transaction, but only if none of the given
 // keys exist. If one or more of the keys already exist, nothing will be changed and MSETNX will return false.
+// The call is limited to 100 keys and 4MB, like MSET.
 //
 // Works similar to https://redis.io/commands/msetnx
 func (c Client) MSETNX(data map[string]Value) (ok bool, err error) {
@@ -161,6 +233,10 @@ func (c Client) MSETNX(data map[string]Value) (ok bool, err error) {
 }
 
 func (c Client) mset(data map[string]Value, flags Flags) (ok bool, err error) {
+	if len(data) > maxTransactionItems {
+		return false, ErrTooManyKeys
+	}
+
 	inputs := make([]dynamodb.TransactWriteItem, 0, len(data))
 
 	for k, v := range data {
@@ -249,11 +325,10 @@ func (c Client) incr(key string, value Value) (newValue ReturnValue, err error)
 // INCR increments the number stored at the key by 1 (n = n + 1) and returns the new value. If the
 // key does not exist, it will be initialized with zero before applying the operation.
 //
-// If there is an existing value at the key with a non-numeric type (string, bytes, etc.)
-// the operation will throw an error. If the existing value is numeric, the operation
-// can continue irrespective of how it was initially set.
+// The existing value must be an integer, stored as a number or as a string, otherwise ErrNotInteger is
+// returned. If the result doesn't fit in an int64, ErrOverflow is returned and the value is left unchanged.
 //
-// Cost is O(1) or 1 WCU.
+// Cost is O(1) / 1 WCU. Values last written by other commands and failed increments cost an extra 1 RCU + 1 WCU.
 //
 // Works similar to https://redis.io/commands/incr
 func (c Client) INCR(key string) (after int64, err error) {
@@ -263,11 +338,10 @@ func (c Client) INCR(key string) (after int64, err error) {
 // DECR decrements the number stored at the key by 1 (n = n - 1) and returns the new value. If the
 // key does not exist, it will be initialized with zero before applying the operation.
 //
-// If there is an existing value at the key with a non-numeric type (string, bytes, etc.)
-// the operation will throw an error. If the existing value is numeric, the operation
-// can continue irrespective of how it was initially set.
+// The existing value must be an integer, stored as a number or as a string, otherwise ErrNotInteger is
+// returned. If the result doesn't fit in an int64, ErrOverflow is returned and the value is left unchanged.
 //
-// Cost is O(1) or 1 WCU.
+// Cost is O(1) / 1 WCU. Values last written by other commands and failed increments cost an extra 1 RCU + 1 WCU.
 //
 // Works similar to https://redis.io/commands/decr
 func (c Client) DECR(key string) (after int64, err error) {
@@ -277,18 +351,16 @@ func (c Client) DECR(key string) (after int64, err error) {
 // INCRBY increments the number stored at the key with the given delta (n = n + delta) and returns the new value. If the
 // key does not exist, it will be initialized with zero before applying the operation.
 //
-// If there is an existing value at the key with a non-numeric type (string, bytes, etc.)
-// the operation will throw an error. If the existing value is numeric, the operation
-// can continue irrespective of how it was initially set.
+// The existing value must be an integer, stored as a number or as a string, otherwise ErrNotInteger is
+// returned. If the result doesn't fit in an int64, ErrOverflow is returned and the value is left unchanged.
 //
-// Cost is O(1) or 1 WCU.
+// Cost is O(1) / 1 WCU. Values last written by other commands and failed increments cost an extra 1 RCU + 1 WCU.
 //
 // Works similar to https://redis.io/commands/incrby
 func (c Client) INCRBY(key string, delta int64) (after int64, err error) {
-	rv, err := c.incr(key, IntValue{delta})
-	if err == nil {
-		after = rv.Int()
-	}
+	after, _, err = c.incrInt(keyDef{pk: key, sk: emptySK}, delta, func(item map[string]dynamodb.AttributeValue) bool {
+		return len(item) > 0
+	})
 
 	return
 }
@@ -296,13 +368,160 @@ func (c Client) INCRBY(key string, delta int64) (after int64, err error) {
 // DECRBY decrements the number stored at the key with the given delta (n = n - delta) and returns the new value. If the
 // key does not exist, it will be initialized with zero before applying the operation.
 //
-// If there is an existing value at the key with a non-numeric type (string, bytes, etc.)
-// the operation will throw an error. If the existing value is numeric, the operation
-// can continue irrespective of how it was initially set.
+// The existing value must be an integer, stored as a number or as a string, otherwise ErrNotInteger is
+// returned. If the result doesn't fit in an int64, ErrOverflow is returned and the value is left unchanged.
 //
-// Cost is O(1) or 1 WCU.
+// Cost is O(1) / 1 WCU. Values last written by other commands and failed increments cost an extra 1 RCU + 1 WCU.
 //
 // Works similar to https://redis.io/commands/decrby
 func (c Client) DECRBY(key string, delta int64) (after int64, err error) {
+	if delta == math.MinInt64 {
+		return 0, ErrOverflow
+	}
+
 	return c.INCRBY(key, -delta)
 }
+
+// ErrOverflow is returned by the integer increment commands when the result would not fit in an int64.
+var ErrOverflow = errors.New("increment or decrement would overflow")
+
+// parseInteger reads an integer stored as a number or as a string, as Redis allows.
+func parseInteger(av dynamodb.AttributeValue) (n int64, err error) {
+	switch {
+	case av.N != nil:
+		n, err = strconv.ParseInt(aws.StringValue(av.N), 10, 64)
+	case av.S != nil:
+		n, err = strconv.ParseInt(aws.StringValue(av.S), 10, 64)
+	default:
+		return 0, ErrNotInteger
+	}
+
+	if err != nil {
+		return 0, ErrNotInteger
+	}
+
+	return n, nil
+}
+
+// addIntegers adds the integers, failing with ErrOverflow instead of wrapping around.
+func addIntegers(a, b int64) (int64, error) {
+	if b > 0 && a > math.MaxInt64-b || b < 0 && a < math.MinInt64-b {
+		return 0, ErrOverflow
+	}
+
+	return a + b, nil
+}
+
+// incrementedKey holds a copy of the value written by the integer increments, so that they can tell a value they
+// wrote themselves – which is always an integer – from one written by any other command.
+const incrementedKey = "ivl"
+
+// incrInt adds delta to the integer in the item at key and reports whether the item had to be created.
+//
+// A condition can't tell an integer from a fractional number, so the sum is written with a single ADD only if the
+// value was last written by an increment, which keeps its copy in incrementedKey equal to the value, and is far
+// enough from the int64 limits for the result to fit. Otherwise incrIntChecked reads the value to tell an
+// integer written by another command, or stored as a string, from a non-integer, an overflow or an item rejected
+// by live (like an expired hash field).
+func (c Client) incrInt(key keyDef, delta int64,
+	live func(item map[string]dynamodb.AttributeValue) bool) (after int64, created bool, err error) {
+	builder := newExpresionBuilder()
+	builder.clauses["ADD"] = append(builder.clauses["ADD"],
+		fmt.Sprintf("#%v :delta", vk), fmt.Sprintf("#%v :delta", incrementedKey))
+	builder.values["delta"] = IntValue{delta}.ToAV()
+
+	operator, limit := "<=", int64(math.MaxInt64)-delta
+	if delta < 0 {
+		operator, limit = ">=", math.MinInt64-delta
+	}
+
+	builder.condition(fmt.Sprintf("(attribute_not_exists(#%v) OR #%v = #%v AND #%v %v :limit)",
+		vk, vk, incrementedKey, vk, operator), vk, incrementedKey)
+	builder.values["limit"] = IntValue{limit}.ToAV()
+
+	builder.condition(fmt.Sprintf("(attribute_not_exists(#%v) OR #%v > :now)", c.ttl, c.ttl), c.ttl)
+	builder.values["now"] = IntValue{hNow()}.ToAV()
+
+	resp, err := c.ddbClient.UpdateItemRequest(&dynamodb.UpdateItemInput{
+		ConditionExpression:       builder.conditionExpression(),
+		ExpressionAttributeNames:  builder.expressionAttributeNames(),
+		ExpressionAttributeValues: builder.expressionAttributeValues(),
+		Key:                       key.toAV(c),
+		ReturnValues:              dynamodb.ReturnValueUpdatedOld,
+		TableName:                 aws.String(c.table),
+		UpdateExpression:          builder.updateExpression(),
+	}).Send(context.TODO())
+	if conditionFailureError(err) {
+		return c.incrIntChecked(key, delta, live)
+	}
+
+	if err != nil {
+		return 0, false, err
+	}
+
+	before, existed := resp.UpdateItemOutput.Attributes[vk]
+
+	return ReturnValue{before}.Int() + delta, !existed, nil
+}
+
+// incrIntChecked is the fallback for incrInt: the value is read and checked first, and the sum is written on the
+// condition that the value hasn't changed in between, along with its copy so that the next increment can take
+// the single ADD. Items that live rejects count as zero and are overwritten.
+func (c Client) incrIntChecked(key keyDef, delta int64,
+	live func(item map[string]dynamodb.AttributeValue) bool) (after int64, created bool, err error) {
+	for retryCount := 0; retryCount < 5; retryCount++ {
+		resp, err := c.ddbClient.GetItemRequest(&dynamodb.GetItemInput{
+			ConsistentRead: aws.Bool(true),
+			Key:            key.toAV(c),
+			TableName:      aws.String(c.table),
+		}).Send(context.TODO())
+		if err != nil {
+			return 0, false, err
+		}
+
+		var before int64
+
+		if live(resp.Item) {
+			if before, err = parseInteger(resp.Item[vk]); err != nil {
+				return 0, false, err
+			}
+		}
+
+		if after, err = addIntegers(before, delta); err != nil {
+			return 0, false, err
+		}
+
+		builder := newExpresionBuilder()
+		builder.updateSET(vk, IntValue{after})
+		builder.updateSET(incrementedKey, IntValue{after})
+
+		if len(resp.Item) == 0 {
+			builder.addConditionNotExists(c.pk)
+		} else {
+			builder.condition(fmt.Sprintf("#%v = :old", vk), vk)
+			builder.values["old"] = resp.Item[vk]
+		}
+
+		if len(resp.Item) > 0 && !live(resp.Item) {
+			c.hRemoveExpiry(&builder)
+		}
+
+		_, err = c.ddbClient.UpdateItemRequest(&dynamodb.UpdateItemInput{
+			ConditionExpression:       builder.conditionExpression(),
+			ExpressionAttributeNames:  builder.expressionAttributeNames(),
+			ExpressionAttributeValues: builder.expressionAttributeValues(),
+			Key:                       key.toAV(c),
+			TableName:                 aws.String(c.table),
+			UpdateExpression:          builder.updateExpression(),
+		}).Send(context.TODO())
+		if err == nil {
+			return after, len(resp.Item) == 0, nil
+		}
+
+		if !conditionFailureError(err) {
+			return 0, false, err
+		}
+	}
+
+	return 0, false, errors.New("too much contention")
+}
@@ -0,0 +1,256 @@
+package redimo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// APPEND, GETRANGE, SETRANGE and STRLEN work on the bytes of string values, as Redis does, and on byte values
+// directly. Numbers are treated as their decimal text, as Redis stores them. DynamoDB can't concatenate or
+// splice values in an update expression, so APPEND and SETRANGE read the value and write the result back on the
+// condition that the value hasn't changed in between, retrying if it has. Results that aren't valid UTF-8 are
+// stored as bytes, since DynamoDB strings must be.
+
+var (
+	// ErrWrongType is returned when the value at the key isn't a string, number or bytes.
+	ErrWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+	// ErrValueTooLarge is returned when a value would make its item larger than DynamoDB's 400KB item size limit.
+	ErrValueTooLarge = errors.New("value exceeds the 400KB DynamoDB item size limit")
+
+	// ErrOffsetOutOfRange is returned by SETRANGE for negative offsets.
+	ErrOffsetOutOfRange = errors.New("offset is out of range")
+)
+
+const maxItemSize = 400 * 1024
+
+// stringBytes returns the bytes of a string, number or bytes value, and whether it was bytes.
+func stringBytes(av dynamodb.AttributeValue) (data []byte, isBytes bool, err error) {
+	switch {
+	case av.S != nil:
+		return []byte(aws.StringValue(av.S)), false, nil
+	case av.N != nil:
+		return []byte(aws.StringValue(av.N)), false, nil
+	case av.B != nil:
+		return av.B, true, nil
+	}
+
+	return nil, false, ErrWrongType
+}
+
+// stringAV stores the data as a string if it can, and as bytes otherwise.
+func stringAV(data []byte, asBytes bool) dynamodb.AttributeValue {
+	if asBytes || !utf8.Valid(data) {
+		return dynamodb.AttributeValue{B: data}
+	}
+
+	return dynamodb.AttributeValue{S: aws.String(string(data))}
+}
+
+// checkStringSize estimates the size of the item holding a string value, as DynamoDB counts it: the lengths
+// of the attribute names and values.
+func (c Client) checkStringSize(key string, valueSize int) error {
+	size := len(c.pk) + len(key) + len(c.sk) + len(emptySK) + len(vk) + valueSize
+	if size > maxItemSize {
+		return ErrValueTooLarge
+	}
+
+	return nil
+}
+
+// modifyString reads the value at key and writes back the result of modify, as long as the value hasn't
+// changed in between. The modify function can return false to leave the value untouched.
+func (c Client) modifyString(key string,
+	modify func(data []byte, isBytes bool, exists bool) (updated []byte, asBytes bool, write bool)) (length int64, err error) {
+	for retryCount := 0; retryCount < 5; retryCount++ {
+		resp, err := c.ddbClient.GetItemRequest(&dynamodb.GetItemInput{
+			ConsistentRead: aws.Bool(true),
+			Key:            keyDef{pk: key, sk: emptySK}.toAV(c),
+			TableName:      aws.String(c.table),
+		}).Send(context.TODO())
+		if err != nil {
+			return 0, err
+		}
+
+		oldAV, exists := resp.Item[vk]
+
+		var data []byte
+
+		isBytes := false
+
+		if exists {
+			if data, isBytes, err = stringBytes(oldAV); err != nil {
+				return 0, err
+			}
+		}
+
+		updated, asBytes, write := modify(data, isBytes, exists)
+		if !write {
+			return int64(len(data)), nil
+		}
+
+		if err = c.checkStringSize(key, len(updated)); err != nil {
+			return 0, err
+		}
+
+		builder := newExpresionBuilder()
+		builder.updateSetAV(vk, stringAV(updated, asBytes))
+
+		if exists {
+			builder.condition(fmt.Sprintf("#%v = :old", vk), vk)
+			builder.values["old"] = oldAV
+		} else {
+			builder.addConditionNotExists(c.pk)
+		}
+
+		_, err = c.ddbClient.UpdateItemRequest(&dynamodb.UpdateItemInput{
+			ConditionExpression:       builder.conditionExpression(),
+			ExpressionAttributeNames:  builder.expressionAttributeNames(),
+			ExpressionAttributeValues: builder.expressionAttributeValues(),
+			Key:                       keyDef{pk: key, sk: emptySK}.toAV(c),
+			TableName:                 aws.String(c.table),
+			UpdateExpression:          builder.updateExpression(),
+		}).Send(context.TODO())
+		if err == nil {
+			return int64(len(updated)), nil
+		}
+
+		if !conditionFailureError(err) {
+			return 0, err
+		}
+	}
+
+	return 0, errors.New("too much contention")
+}
+
+// APPEND appends the value to the one at key, creating it if it doesn't exist, and returns the new length in
+// bytes. Appending bytes to a string, or a string to bytes, makes the value bytes.
+//
+// Cost is O(1) / 1 RCU + 1 WCU per 1KB of the new value.
+//
+// Works similar to https://redis.io/commands/append
+func (c Client) APPEND(key string, value Value) (length int64, err error) {
+	suffix, suffixIsBytes, err := stringBytes(value.ToAV())
+	if err != nil {
+		return
+	}
+
+	return c.modifyString(key, func(data []byte, isBytes bool, exists bool) ([]byte, bool, bool) {
+		updated := make([]byte, 0, len(data)+len(suffix))
+		updated = append(append(updated, data...), suffix...)
+
+		return updated, isBytes || suffixIsBytes, true
+	})
+}
+
+// STRLEN returns the length in bytes of the value at key, or 0 if it doesn't exist.
+//
+// Cost is O(1) / 1 RCU.
+//
+// Works similar to https://redis.io/commands/strlen
+func (c Client) STRLEN(key string) (length int64, err error) {
+	val, err := c.GET(key)
+	if err != nil || val.Empty() {
+		return
+	}
+
+	data, _, err := stringBytes(val.ToAV())
+
+	return int64(len(data)), err
+}
+
+// GETRANGE returns the bytes of the value at key from start to end, both inclusive. Negative offsets count from
+// the end, so -1 is the last byte. The range is clamped to the value, and an empty value is returned if it's
+// empty or the key doesn't exist. Strings stay strings as long as the range is valid UTF-8.
+//
+// Cost is O(1) / 1 RCU per 4KB of the value.
+//
+// Works similar to https://redis.io/commands/getrange
+func (c Client) GETRANGE(key string, start, end int64) (val ReturnValue, err error) {
+	current, err := c.GET(key)
+	if err != nil || current.Empty() {
+		return ReturnValue{StringValue{""}.ToAV()}, err
+	}
+
+	data, isBytes, err := stringBytes(current.ToAV())
+	if err != nil {
+		return
+	}
+
+	from, to := byteRange(int64(len(data)), start, end)
+
+	return ReturnValue{stringAV(data[from:to], isBytes)}, nil
+}
+
+// byteRange converts Redis style inclusive offsets into slice bounds for a value of the given length.
+func byteRange(length, start, end int64) (from, to int64) {
+	if start < 0 {
+		start += length
+	}
+
+	if end < 0 {
+		end += length
+	}
+
+	if start < 0 {
+		start = 0
+	}
+
+	if end >= length {
+		end = length - 1
+	}
+
+	if start > end {
+		return 0, 0
+	}
+
+	return start, end + 1
+}
+
+// SETRANGE overwrites the value at key from the offset with the given value, padding it with zero bytes if it's
+// shorter than the offset, and returns the new length in bytes. Setting an empty value on a missing key doesn't
+// create it.
+//
+// Cost is O(1) / 1 RCU + 1 WCU per 1KB of the new value.
+//
+// Works similar to https://redis.io/commands/setrange
+func (c Client) SETRANGE(key string, offset int64, value Value) (length int64, err error) {
+	if offset < 0 {
+		return 0, ErrOffsetOutOfRange
+	}
+
+	if offset > maxItemSize {
+		return 0, ErrValueTooLarge
+	}
+
+	patch, patchIsBytes, err := stringBytes(value.ToAV())
+	if err != nil {
+		return
+	}
+
+	if err = c.checkStringSize(key, int(offset)+len(patch)); err != nil {
+		return
+	}
+
+	return c.modifyString(key, func(data []byte, isBytes bool, exists bool) ([]byte, bool, bool) {
+		if len(patch) == 0 {
+			return data, isBytes, false
+		}
+
+		size := len(data)
+		if end := int(offset) + len(patch); end > size {
+			size = end
+		}
+
+		updated := make([]byte, size)
+		copy(updated, data)
+		copy(updated[offset:], patch)
+
+		return updated, isBytes || patchIsBytes, true
+	})
+}
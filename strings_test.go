@@ -1,8 +1,12 @@
 package redimo
 
 import (
+	"fmt"
+	"math"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -103,9 +107,9 @@ func TestAtomicOps(t *testing.T) {
 	values, err := c.MGET([]string{"k1", "k2", "k3"}...)
 	assert.NoError(t, err)
 	assert.Len(t, values, 3)
-	assert.Equal(t, "v1", values["k1"].String())
-	assert.Equal(t, "v2", values["k2"].String())
-	assert.Equal(t, "v3", values["k3"].String())
+	assert.Equal(t, "v1", values[0].String())
+	assert.Equal(t, "v2", values[1].String())
+	assert.Equal(t, "v3", values[2].String())
 
 	err = c.MSET(map[string]Value{"k3": StringValue{"v3.1"}, "k4": StringValue{"v4"}})
 	assert.NoError(t, err)
@@ -114,11 +118,11 @@ func TestAtomicOps(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "v3.1", v.String())
 
-	values, err = c.MGET("k3", "k4")
+	values, err = c.MGET("k4", "k3")
 	assert.NoError(t, err)
 	assert.Len(t, values, 2)
-	assert.Equal(t, "v3.1", values["k3"].String())
-	assert.Equal(t, "v4", values["k4"].String())
+	assert.Equal(t, "v4", values[0].String())
+	assert.Equal(t, "v3.1", values[1].String())
 
 	ok, err := c.MSETNX(map[string]Value{"k3": StringValue{"v3.2"}, "k5": StringValue{"v5"}})
 	assert.NoError(t, err)
@@ -127,8 +131,8 @@ func TestAtomicOps(t *testing.T) {
 	values, err = c.MGET([]string{"k3", "k5"}...)
 	assert.NoError(t, err)
 	assert.Len(t, values, 2)
-	assert.Equal(t, "v3.1", values["k3"].String())
-	assert.False(t, values["k5"].Present())
+	assert.Equal(t, "v3.1", values[0].String())
+	assert.False(t, values[1].Present())
 	assert.NoError(t, err)
 
 	ok, err = c.MSETNX(map[string]Value{"k5": StringValue{"v5"}, "k6": StringValue{"v6"}})
@@ -138,6 +142,291 @@ func TestAtomicOps(t *testing.T) {
 	values, err = c.MGET("k5", "k6")
 	assert.NoError(t, err)
 	assert.Len(t, values, 2)
-	assert.Equal(t, "v5", values["k5"].String())
-	assert.Equal(t, "v6", values["k6"].String())
+	assert.Equal(t, "v5", values[0].String())
+	assert.Equal(t, "v6", values[1].String())
+}
+
+func TestBatchOps(t *testing.T) {
+	c := newClient(t)
+
+	data := make(map[string]Value)
+
+	var keys []string
+
+	for i := 0; i < 250; i++ {
+		key := fmt.Sprintf("k%03d", i)
+		keys = append(keys, key)
+		data[key] = IntValue{int64(i)}
+	}
+
+	err := c.MSET(data)
+	assert.Equal(t, ErrTooManyKeys, err)
+
+	_, err = c.MGET(keys...)
+	assert.Equal(t, ErrTooManyKeys, err)
+
+	assert.NoError(t, c.MSETBATCH(data))
+
+	keys = append([]string{"nosuchkey", "k249"}, keys...)
+
+	values, err := c.MGETBATCH(keys...)
+	assert.NoError(t, err)
+	assert.Len(t, values, 252)
+	assert.False(t, values[0].Present())
+	assert.Equal(t, int64(249), values[1].Int())
+
+	for i, val := range values[2:] {
+		assert.Equal(t, int64(i), val.Int())
+	}
+}
+
+func TestByteRange(t *testing.T) {
+	for _, tc := range []struct {
+		length, start, end int64
+		from, to           int64
+	}{
+		{10, 0, 3, 0, 4},
+		{10, -3, -1, 7, 10},
+		{10, 0, -1, 0, 10},
+		{10, 10, 100, 0, 0},
+		{10, 5, 3, 0, 0},
+		{10, -100, 2, 0, 3},
+		{10, 0, 100, 0, 10},
+		{0, 0, -1, 0, 0},
+	} {
+		from, to := byteRange(tc.length, tc.start, tc.end)
+		assert.Equal(t, [2]int64{tc.from, tc.to}, [2]int64{from, to}, tc)
+	}
+}
+
+func TestStringRanges(t *testing.T) {
+	c := newClient(t)
+
+	length, err := c.APPEND("k1", StringValue{"Hello"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), length)
+
+	length, err = c.APPEND("k1", StringValue{" World"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(11), length)
+
+	val, err := c.GETRANGE("k1", 0, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello", val.String())
+
+	val, err = c.GETRANGE("k1", -5, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, "World", val.String())
+
+	val, err = c.GETRANGE("k1", 20, 30)
+	assert.NoError(t, err)
+	assert.Equal(t, "", val.String())
+
+	length, err = c.SETRANGE("k1", 6, StringValue{"Redis"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(11), length)
+
+	val, err = c.GET("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello Redis", val.String())
+
+	length, err = c.SETRANGE("k2", 3, StringValue{"abc"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), length)
+
+	val, err = c.GET("k2")
+	assert.NoError(t, err)
+	assert.Equal(t, "\x00\x00\x00abc", val.String())
+
+	length, err = c.SETRANGE("k3", 3, StringValue{""})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), length)
+
+	val, err = c.GET("k3")
+	assert.NoError(t, err)
+	assert.True(t, val.Empty())
+
+	_, err = c.SETRANGE("k3", -1, StringValue{"a"})
+	assert.Equal(t, ErrOffsetOutOfRange, err)
+
+	_, err = c.SET("bytes", BytesValue{[]byte{1, 2, 3}}, None)
+	assert.NoError(t, err)
+
+	length, err = c.APPEND("bytes", StringValue{"a"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), length)
+
+	val, err = c.GETRANGE("bytes", 1, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{2, 3}, val.Bytes())
+
+	_, err = c.SET("number", IntValue{42}, None)
+	assert.NoError(t, err)
+
+	length, err = c.STRLEN("number")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), length)
+
+	length, err = c.APPEND("number", IntValue{7})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), length)
+
+	val, err = c.GET("number")
+	assert.NoError(t, err)
+	assert.Equal(t, "427", val.String())
+
+	length, err = c.STRLEN("nosuchkey")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), length)
+
+	_, err = c.SET("list", listValue{}, None)
+	assert.NoError(t, err)
+
+	_, err = c.APPEND("list", StringValue{"a"})
+	assert.Equal(t, ErrWrongType, err)
+
+	_, err = c.STRLEN("list")
+	assert.Equal(t, ErrWrongType, err)
+
+	_, err = c.SETRANGE("k1", 400*1024, StringValue{"a"})
+	assert.Equal(t, ErrValueTooLarge, err)
+
+	_, err = c.APPEND("k1", BytesValue{make([]byte, 400*1024)})
+	assert.Equal(t, ErrValueTooLarge, err)
+}
+
+type listValue struct{}
+
+func (listValue) ToAV() dynamodb.AttributeValue {
+	return dynamodb.AttributeValue{L: []dynamodb.AttributeValue{{S: aws.String("a")}}}
+}
+
+func TestAddIntegers(t *testing.T) {
+	for _, tc := range []struct {
+		a, b int64
+		sum  int64
+		err  error
+	}{
+		{1, 2, 3, nil},
+		{math.MaxInt64 - 1, 1, math.MaxInt64, nil},
+		{math.MaxInt64, 1, 0, ErrOverflow},
+		{math.MinInt64 + 1, -1, math.MinInt64, nil},
+		{math.MinInt64, -1, 0, ErrOverflow},
+		{math.MaxInt64, math.MinInt64, -1, nil},
+		{-1, math.MinInt64, 0, ErrOverflow},
+	} {
+		sum, err := addIntegers(tc.a, tc.b)
+		assert.Equal(t, tc.err, err, tc)
+		assert.Equal(t, tc.sum, sum, tc)
+	}
+
+	for _, tc := range []struct {
+		av  dynamodb.AttributeValue
+		n   int64
+		err error
+	}{
+		{IntValue{42}.ToAV(), 42, nil},
+		{StringValue{"-17"}.ToAV(), -17, nil},
+		{FloatValue{1.5}.ToAV(), 0, ErrNotInteger},
+		{StringValue{"abc"}.ToAV(), 0, ErrNotInteger},
+		{StringValue{"9223372036854775808"}.ToAV(), 0, ErrNotInteger},
+		{BytesValue{[]byte{1}}.ToAV(), 0, ErrNotInteger},
+	} {
+		n, err := parseInteger(tc.av)
+		assert.Equal(t, tc.err, err, tc)
+		assert.Equal(t, tc.n, n, tc)
+	}
+}
+
+func TestIntegerCounters(t *testing.T) {
+	c := newClient(t)
+
+	_, err := c.SET("max", IntValue{math.MaxInt64 - 1}, None)
+	assert.NoError(t, err)
+
+	count, err := c.INCR("max")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(math.MaxInt64), count)
+
+	_, err = c.INCR("max")
+	assert.Equal(t, ErrOverflow, err)
+
+	val, err := c.GET("max")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(math.MaxInt64), val.Int())
+
+	count, err = c.DECRBY("min", math.MaxInt64)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-math.MaxInt64), count)
+
+	count, err = c.DECR("min")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(math.MinInt64), count)
+
+	_, err = c.DECR("min")
+	assert.Equal(t, ErrOverflow, err)
+
+	_, err = c.DECRBY("other", math.MinInt64)
+	assert.Equal(t, ErrOverflow, err)
+
+	_, err = c.SET("float", FloatValue{1.5}, None)
+	assert.NoError(t, err)
+
+	_, err = c.INCRBY("float", 1)
+	assert.Equal(t, ErrNotInteger, err)
+
+	val, err = c.GET("float")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, val.Float())
+
+	_, err = c.SET("text", StringValue{"10"}, None)
+	assert.NoError(t, err)
+
+	count, err = c.INCR("text")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(11), count)
+
+	_, err = c.SET("text", StringValue{"ten"}, None)
+	assert.NoError(t, err)
+
+	_, err = c.INCR("text")
+	assert.Equal(t, ErrNotInteger, err)
+
+	_, err = c.HSET("h1", map[string]Value{"max": IntValue{math.MaxInt64}, "float": FloatValue{2.5}})
+	assert.NoError(t, err)
+
+	_, err = c.HINCRBY("h1", "max", 1)
+	assert.Equal(t, ErrOverflow, err)
+
+	_, err = c.HINCRBY("h1", "float", 1)
+	assert.Equal(t, ErrNotInteger, err)
+
+	val, err = c.HGET("h1", "float")
+	assert.NoError(t, err)
+	assert.Equal(t, 2.5, val.Float())
+
+	count, err = c.HINCRBY("h1", "max", math.MinInt64)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), count)
+
+	count, err = c.HINCRBY("h1", "counter", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	_, err = c.HSET("h1", map[string]Value{"counter": FloatValue{2.5}})
+	assert.NoError(t, err)
+
+	_, err = c.HINCRBY("h1", "counter", 1)
+	assert.Equal(t, ErrNotInteger, err)
+
+	_, err = c.HSET("h1", map[string]Value{"counter": IntValue{7}})
+	assert.NoError(t, err)
+
+	count, err = c.HINCRBY("h1", "counter", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(8), count)
+
+	count, err = c.HINCRBY("h1", "counter", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(9), count)
 }